@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http/httputil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/0xPolygonID/refresh-service/providers/lint"
+	"github.com/0xPolygonID/refresh-service/providers/onchain"
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/kelseyhightower/envconfig"
+	"github.com/pkg/errors"
+)
+
+// CommandConfig is the part of the configuration used by subcommands.
+// Issuers and state contracts aren't required, subcommands don't talk to issuer nodes.
+type CommandConfig struct {
+	IPFSGWURL       string        `envconfig:"IPFS_GATEWAY_URL" default:"https://ipfs.io"`
+	HTTPConfigPath  string        `envconfig:"HTTP_CONFIG_PATH" default:"config.yaml"`
+	SupportedRPC    KVstring      `envconfig:"SUPPORTED_RPC"`
+	RefreshTimeout  time.Duration `envconfig:"REFRESH_TIMEOUT" default:"60s"`
+	ProviderTimeout time.Duration `envconfig:"PROVIDER_TIMEOUT" default:"30s"`
+}
+
+type command struct {
+	description string
+	run         func(cfg CommandConfig, args []string, out io.Writer) error
+}
+
+var commands = map[string]command{
+	"validate-config": {
+		description: "validate the data provider configuration and print the report",
+		run:         validateConfigCommand,
+	},
+	"test-provider": {
+		description: "build the request of a data provider, call it and print the mapped fields",
+		run:         testProviderCommand,
+	},
+	"simulate-refresh": {
+		description: "run the refresh of a credential up to, but not including, the issuance",
+		run:         simulateRefreshCommand,
+	},
+}
+
+// runCommand runs the subcommand. Without a subcommand the server is started.
+func runCommand(name string, args []string, out io.Writer) error {
+	cmd, ok := commands[name]
+	if !ok {
+		usage(out)
+		if name == "help" || name == "-h" || name == "--help" {
+			return nil
+		}
+		return errors.Errorf("unknown command '%s'", name)
+	}
+	var cfg CommandConfig
+	if err := envconfig.Process("", &cfg); err != nil {
+		return errors.Errorf("failed init config: %v", err)
+	}
+	return cmd.run(cfg, args, out)
+}
+
+func usage(out io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(out, "Usage: refresh-service [command] [flags]")
+	fmt.Fprintln(out, "Without a command the server is started.")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-18s %s\n", name, commands[name].description)
+	}
+	fmt.Fprintln(out, "\nRun 'refresh-service <command> -h' for the flags of the command.")
+}
+
+func newFlagSet(name string, cfg *CommandConfig, out io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.StringVar(&cfg.HTTPConfigPath, "config", cfg.HTTPConfigPath, "path to the data provider configuration")
+	return fs
+}
+
+func validateConfigCommand(cfg CommandConfig, args []string, out io.Writer) error {
+	fs := newFlagSet("validate-config", &cfg, out)
+	jsonld := fs.Bool("jsonld", false, "check mapped fields against JSON-LD contexts of credential types")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var opts []lint.Option
+	if *jsonld {
+		documentLoader, err := initDocumentLoaderWithCache(cfg.IPFSGWURL)
+		if err != nil {
+			return errors.Errorf("failed init document loader: %v", err)
+		}
+		opts = append(opts, lint.WithDocumentLoader(documentLoader))
+	}
+	report, err := lint.Lint(cfg.HTTPConfigPath, opts...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, report.String())
+	if report.HasErrors() {
+		return errors.New("the configuration has errors")
+	}
+	return nil
+}
+
+func testProviderCommand(cfg CommandConfig, args []string, out io.Writer) error {
+	fs := newFlagSet("test-provider", &cfg, out)
+	credentialType := fs.String("type", "", "credential type, a key of the configuration file")
+	subjectPath := fs.String("subject", "", "path to a JSON file with the credential subject")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *credentialType == "" || *subjectPath == "" {
+		fs.Usage()
+		return errors.New("-type and -subject are required")
+	}
+
+	subject := make(map[string]interface{})
+	if err := readJSON(*subjectPath, &subject); err != nil {
+		return err
+	}
+	credential := &verifiable.W3CCredential{CredentialSubject: subject}
+
+	registry, err := initCommandProviders(cfg)
+	if err != nil {
+		return err
+	}
+	provider, settings, err := registry.Get(*credentialType)
+	if err != nil {
+		return err
+	}
+
+	timeout := cfg.ProviderTimeout
+	if settings.Timeout > 0 {
+		timeout = settings.Timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	switch p := provider.(type) {
+	case *flexiblehttp.FlexibleHTTP:
+		did, _ := subject["id"].(string)
+		data, err := flexiblehttp.NewTemplateData(credential, did)
+		if err != nil {
+			return err
+		}
+		// credentials from the auth section and signatures are not printed
+		request, err := p.BuildRedactedRequest(ctx, data)
+		if err != nil {
+			return errors.Errorf("failed to build request: %v", err)
+		}
+		dump, err := httputil.DumpRequestOut(request, true)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Request:\n%s\n\n", bytes.TrimSpace(dump))
+	case *onchain.Onchain:
+		msg, err := p.BuildCall(credential)
+		if err != nil {
+			return errors.Errorf("failed to build call: %v", err)
+		}
+		fmt.Fprintf(out, "Call:\nto: %s\ndata: 0x%s\n\n", msg.To, hex.EncodeToString(msg.Data))
+	}
+
+	fields, err := provider.Provide(ctx, credential)
+	if err != nil {
+		return errors.Errorf("failed to provide fields: %v", err)
+	}
+	fmt.Fprintln(out, "Mapped fields:")
+	return printJSON(out, fields)
+}
+
+func simulateRefreshCommand(cfg CommandConfig, args []string, out io.Writer) error {
+	fs := newFlagSet("simulate-refresh", &cfg, out)
+	credentialPath := fs.String("credential", "", "path to a JSON file with the W3C credential")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *credentialPath == "" {
+		fs.Usage()
+		return errors.New("-credential is required")
+	}
+
+	var credential verifiable.W3CCredential
+	if err := readJSON(*credentialPath, &credential); err != nil {
+		return err
+	}
+
+	documentLoader, err := initDocumentLoaderWithCache(cfg.IPFSGWURL)
+	if err != nil {
+		return errors.Errorf("failed init document loader: %v", err)
+	}
+	registry, err := initCommandProviders(cfg)
+	if err != nil {
+		return err
+	}
+	refreshService := service.NewRefreshService(
+		nil,
+		documentLoader,
+		registry,
+		service.WithRefreshTimeout(cfg.RefreshTimeout),
+		service.WithProviderTimeout(cfg.ProviderTimeout),
+	)
+
+	request, err := refreshService.Simulate(context.Background(), &credential)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Request to the issuer node:")
+	return printJSON(out, request)
+}
+
+func initCommandProviders(cfg CommandConfig) (*providers.Registry, error) {
+	contractCallers, err := initContractCallers(cfg.SupportedRPC)
+	if err != nil {
+		return nil, errors.Errorf("failed init contract callers: %v", err)
+	}
+	//nolint:gosec // the path is defined by the user of the command
+	config, err := os.ReadFile(cfg.HTTPConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	return initProviders(config, contractCallers)
+}
+
+func readJSON(path string, v interface{}) error {
+	//nolint:gosec // the path is defined by the user of the command
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return errors.Errorf("invalid JSON in '%s': %v", path, err)
+	}
+	return nil
+}
+
+func printJSON(out io.Writer, v interface{}) error {
+	e := json.NewEncoder(out)
+	e.SetIndent("", "  ")
+	return e.Encode(v)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAPIKey     = "api-key-value"
+	testHMACSecret = "hmac-secret-value"
+
+	testCredentialType = "urn:test#Balance"
+	testSubjectDID     = "did:polygonid:polygon:amoy:2qQ68JkRcf3ymy9wtzKyY3Dajst9c6cHCDZyx7NrTz"
+)
+
+const balanceContext = `{
+  "@context": {
+    "@version": 1.1,
+    "@protected": true,
+    "id": "@id",
+    "type": "@type",
+    "SparseMerkleTreeProof": "urn:test#SparseMerkleTreeProof",
+    "revocationNonce": {"@id": "urn:test#revocationNonce", "@type": "http://www.w3.org/2001/XMLSchema#integer"},
+    "Balance": {
+      "@id": "urn:test#Balance",
+      "@context": {
+        "@version": 1.1,
+        "@protected": true,
+        "id": "@id",
+        "type": "@type",
+        "balance": {"@id": "urn:test#balance", "@type": "http://www.w3.org/2001/XMLSchema#integer"}
+      }
+    }
+  }
+}`
+
+// newTestProvider starts a data provider that requires the API key
+// and serves the JSON-LD context of the test credential type.
+func newTestProvider(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/balance.jsonld":
+			w.Header().Set("Content-Type", "application/ld+json")
+			_, _ = w.Write([]byte(balanceContext))
+		case "/balance":
+			if r.URL.Query().Get("apikey") != testAPIKey || r.Header.Get("X-Signature") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"balance": 200}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func writeTestConfig(t *testing.T, providerURL string) string {
+	return writeFile(t, "config.yaml", fmt.Sprintf(`%s:
+  settings:
+    timeExpiration: 1h
+  provider:
+    url: %s/balance
+  requestSchema:
+    params:
+      address: "{{ credentialSubject.id }}"
+  auth:
+    type: apikey
+    in: query
+    name: apikey
+    value:
+      env: PROVIDER_TEST_API_KEY
+  signing:
+    scheme: hmac-sha256
+    secret:
+      env: PROVIDER_TEST_HMAC_SECRET
+    keyID: refresh-service
+  responseSchema:
+    type: json
+    properties:
+      balance:
+        type: integer
+        match: credentialSubject.balance
+`, testCredentialType, providerURL))
+}
+
+func TestValidateConfigCommand(t *testing.T) {
+	srv := newTestProvider(t)
+	tests := []struct {
+		name           string
+		config         string
+		expectedError  string
+		expectedOutput string
+	}{
+		{
+			name:           "Valid configuration",
+			config:         writeTestConfig(t, srv.URL),
+			expectedOutput: "0 errors, 0 warnings",
+		},
+		{
+			name: "Invalid configuration",
+			config: writeFile(t, "config.yaml", `urn:test#Balance:
+  provider:
+    url: https://example.com
+  responseSchema:
+    properties:
+      balance:
+        type: int
+        match: credentialSubject.balance
+`),
+			expectedError:  "the configuration has errors",
+			expectedOutput: "unsupported type 'int'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			err := validateConfigCommand(CommandConfig{HTTPConfigPath: tt.config}, nil, out)
+			if tt.expectedError != "" {
+				require.ErrorContains(t, err, tt.expectedError)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Contains(t, out.String(), tt.expectedOutput)
+		})
+	}
+}
+
+func TestTestProviderCommand(t *testing.T) {
+	t.Setenv("PROVIDER_TEST_API_KEY", testAPIKey)
+	t.Setenv("PROVIDER_TEST_HMAC_SECRET", testHMACSecret)
+	srv := newTestProvider(t)
+	cfg := CommandConfig{HTTPConfigPath: writeTestConfig(t, srv.URL), ProviderTimeout: time.Second}
+	subject := writeFile(t, "subject.json", fmt.Sprintf(`{"id": %q, "type": "Balance"}`, testSubjectDID))
+
+	out := &bytes.Buffer{}
+	err := testProviderCommand(cfg, []string{"-type", testCredentialType, "-subject", subject}, out)
+	require.NoError(t, err)
+
+	output := out.String()
+	require.Contains(t, output, "GET /balance?")
+	require.Contains(t, output, "address="+strings.ReplaceAll(testSubjectDID, ":", "%3A"))
+	require.Contains(t, output, "apikey=%3Credacted%3E")
+	require.Contains(t, output, "X-Signature: <redacted>")
+	require.Contains(t, output, "X-Key-Id: <redacted>")
+	require.NotContains(t, output, testAPIKey)
+	require.NotContains(t, output, "refresh-service")
+	require.Contains(t, output, `"balance": 200`)
+}
+
+func TestTestProviderCommand_Error(t *testing.T) {
+	srv := newTestProvider(t)
+	cfg := CommandConfig{HTTPConfigPath: writeTestConfig(t, srv.URL), ProviderTimeout: time.Second}
+	subject := writeFile(t, "subject.json", `{}`)
+
+	err := testProviderCommand(cfg, []string{"-subject", subject}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "-type and -subject are required")
+
+	err = testProviderCommand(cfg, []string{"-type", "urn:test#Unknown", "-subject", subject}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "provider not found")
+}
+
+func TestSimulateRefreshCommand(t *testing.T) {
+	t.Setenv("PROVIDER_TEST_API_KEY", testAPIKey)
+	t.Setenv("PROVIDER_TEST_HMAC_SECRET", testHMACSecret)
+	srv := newTestProvider(t)
+	cfg := CommandConfig{
+		HTTPConfigPath:  writeTestConfig(t, srv.URL),
+		RefreshTimeout:  5 * time.Second,
+		ProviderTimeout: time.Second,
+	}
+
+	credential := func(expiration time.Time) string {
+		return writeFile(t, "credential.json", fmt.Sprintf(`{
+  "id": "urn:uuid:8a5c1a2e-4b4f-4c6e-9d1f-2b3c4d5e6f70",
+  "@context": ["https://www.w3.org/2018/credentials/v1", "%s/balance.jsonld"],
+  "type": ["VerifiableCredential", "Balance"],
+  "expirationDate": %q,
+  "issuanceDate": "2024-01-01T00:00:00Z",
+  "issuer": "did:polygonid:polygon:amoy:2qV9QXdhXXmN5sKjN1YueMjxgRbnJcEGK2kGpvk3cq",
+  "credentialSubject": {"id": %q, "type": "Balance", "balance": 100},
+  "credentialStatus": {"id": "https://issuer.example.com/status/7", "revocationNonce": 7, "type": "SparseMerkleTreeProof"}
+}`, srv.URL, expiration.Format(time.RFC3339), testSubjectDID))
+	}
+
+	tests := []struct {
+		name           string
+		credential     string
+		expectedError  string
+		expectedOutput []string
+	}{
+		{
+			name:       "Expired credential",
+			credential: credential(time.Now().Add(-time.Hour)),
+			expectedOutput: []string{
+				"Request to the issuer node:",
+				`"balance": 200`,
+				`"revNonce": 7`,
+			},
+		},
+		{
+			name:          "Not expired credential",
+			credential:    credential(time.Now().Add(time.Hour)),
+			expectedError: "refresh is not allowed yet",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			err := simulateRefreshCommand(cfg, []string{"-credential", tt.credential}, out)
+			if tt.expectedError != "" {
+				require.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			for _, s := range tt.expectedOutput {
+				require.Contains(t, out.String(), s)
+			}
+		})
+	}
+}
@@ -1,15 +1,28 @@
 package main
 
 import (
+	"context"
 	_ "embed"
 	"log"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
-	_ "github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/logger"
 	"github.com/0xPolygonID/refresh-service/packagemanager"
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/0xPolygonID/refresh-service/providers/chain"
 	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/0xPolygonID/refresh-service/providers/lint"
+	"github.com/0xPolygonID/refresh-service/providers/onchain"
 	"github.com/0xPolygonID/refresh-service/server"
 	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/iden3/go-schema-processor/v2/loaders"
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -48,15 +61,25 @@ func (c *KVstring) Decode(value string) error {
 }
 
 type Config struct {
-	SupportedIssuers          KVstring `envconfig:"SUPPORTED_ISSUERS" required:"true"`
-	IPFSGWURL                 string   `envconfig:"IPFS_GATEWAY_URL" default:"https://ipfs.io"`
-	ServerHost                string   `envconfig:"SERVER_HOST" default:":8002"`
-	HTTPConfigPath            string   `envconfig:"HTTP_CONFIG_PATH" default:"config.yaml"`
-	SupportedRPC              KVstring `envconfig:"SUPPORTED_RPC" required:"true"`
-	SupportedStateContracts   KVstring `envconfig:"SUPPORTED_STATE_CONTRACTS" required:"true"`
-	CircuitsFolderPath        string   `envconfig:"CIRCUITS_FOLDER_PATH" default:"keys"`
-	SupportedIssuersBasicAuth KVstring `envconfig:"ISSUERS_BASIC_AUTH"`
-	SupportedCustomDIDMethods string   `envconfig:"SUPPORTED_CUSTOM_DID_METHODS"`
+	SupportedIssuers          KVstring      `envconfig:"SUPPORTED_ISSUERS" required:"true"`
+	IPFSGWURL                 string        `envconfig:"IPFS_GATEWAY_URL" default:"https://ipfs.io"`
+	ServerHost                string        `envconfig:"SERVER_HOST" default:":8002"`
+	HTTPConfigPath            string        `envconfig:"HTTP_CONFIG_PATH" default:"config.yaml"`
+	SupportedRPC              KVstring      `envconfig:"SUPPORTED_RPC" required:"true"`
+	SupportedStateContracts   KVstring      `envconfig:"SUPPORTED_STATE_CONTRACTS" required:"true"`
+	CircuitsFolderPath        string        `envconfig:"CIRCUITS_FOLDER_PATH" default:"keys"`
+	SupportedIssuersBasicAuth KVstring      `envconfig:"ISSUERS_BASIC_AUTH"`
+	SupportedCustomDIDMethods string        `envconfig:"SUPPORTED_CUSTOM_DID_METHODS"`
+	RefreshTimeout            time.Duration `envconfig:"REFRESH_TIMEOUT" default:"60s"`
+	IssuerTimeout             time.Duration `envconfig:"ISSUER_TIMEOUT" default:"15s"`
+	ProviderTimeout           time.Duration `envconfig:"PROVIDER_TIMEOUT" default:"30s"`
+	ConfigValidation          string        `envconfig:"CONFIG_VALIDATION" default:"error"`
+	ConfigValidationJSONLD    bool          `envconfig:"CONFIG_VALIDATION_JSONLD" default:"false"`
+	ConfigReloadInterval      time.Duration `envconfig:"CONFIG_RELOAD_INTERVAL" default:"10s"`
+	RevokeSuperseded          bool          `envconfig:"REVOKE_SUPERSEDED" default:"false"`
+	RefreshResultTTL          time.Duration `envconfig:"REFRESH_RESULT_TTL" default:"0"`
+	StorageURL                string        `envconfig:"STORAGE_URL"`
+	AdminToken                string        `envconfig:"ADMIN_TOKEN"`
 }
 
 func (c *Config) getServerHost() string {
@@ -78,6 +101,13 @@ func main() {
 		log.Printf("Error loading .env file: %v", err)
 	}
 
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if err := envconfig.Process("", &cfg); err != nil {
 		log.Fatalf("failed init config: %v", err)
 	}
@@ -103,18 +133,57 @@ func main() {
 		log.Fatalf("failed init document loader: %v", err)
 	}
 
-	flexhttp, err := flexiblehttp.NewFactoryFlexibleHTTP(
-		cfg.HTTPConfigPath,
-		nil,
-	)
+	contractCallers, err := initContractCallers(cfg.SupportedRPC)
 	if err != nil {
-		log.Fatalf("failed init flexiblehttp: %v", err)
+		log.Fatalf("failed init contract callers: %v", err)
+	}
+	registry := providers.NewRegistry()
+	reloader := providers.NewReloader(cfg.HTTPConfigPath, registry, func(config []byte) (*providers.Registry, error) {
+		if err := validateConfig(cfg, config, documentLoader); err != nil {
+			return nil, errors.Errorf("invalid data provider configuration: %v", err)
+		}
+		registry, err := initProviders(config, contractCallers)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.StorageURL == "" {
+			warnMemoryRefreshCounter(registry)
+		}
+		return registry, nil
+	})
+	if err := reloader.Reload(); err != nil {
+		log.Fatalf("failed init data providers: %v", err)
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go reloader.Watch(context.Background(), cfg.ConfigReloadInterval, hup)
+
+	refreshOpts := []service.Option{
+		service.WithRefreshTimeout(cfg.RefreshTimeout),
+		service.WithIssuerTimeout(cfg.IssuerTimeout),
+		service.WithProviderTimeout(cfg.ProviderTimeout),
+		service.WithRevokeSuperseded(cfg.RevokeSuperseded),
+	}
+	if cfg.RefreshResultTTL > 0 {
+		refreshOpts = append(refreshOpts, service.WithResultStore(nil, cfg.RefreshResultTTL))
+	}
+	var history storage.Storage
+	if cfg.StorageURL != "" {
+		history, err = storage.Open(context.Background(), cfg.StorageURL)
+		if err != nil {
+			log.Fatalf("failed init storage: %v", err)
+		}
+		refreshOpts = append(refreshOpts,
+			service.WithHistory(history, server.ErrorCode),
+			service.WithRefreshCounter(service.NewStorageRefreshCounter(history)),
+		)
 	}
 
 	refreshService := service.NewRefreshService(
 		issuerService,
 		documentLoader,
-		flexhttp,
+		registry,
+		refreshOpts...,
 	)
 
 	agentService := service.NewAgentService(
@@ -122,13 +191,104 @@ func main() {
 		packageManager,
 	)
 
+	var serverOpts []server.Option
+	if cfg.AdminToken != "" {
+		admin, err := server.NewAdmin(server.AdminConfig{
+			Token:            cfg.AdminToken,
+			History:          history,
+			Providers:        registry,
+			Reloader:         reloader,
+			Refresh:          refreshService,
+			Issuers:          cfg.getSupportedIssuers(),
+			IssuersBasicAuth: cfg.SupportedIssuersBasicAuth,
+			RPC:              cfg.SupportedRPC,
+			StateContracts:   cfg.SupportedStateContracts,
+		})
+		if err != nil {
+			log.Fatalf("failed init admin API: %v", err)
+		}
+		serverOpts = append(serverOpts, server.WithAdmin(admin))
+	}
+
 	h := server.NewHandlers(
 		agentService,
+		serverOpts...,
 	)
 
 	log.Fatal(h.Run(cfg.getServerHost()))
 }
 
+// validateConfig lints the content of the data provider configuration file. Depending on CONFIG_VALIDATION
+// errors prevent the service from starting (error), are only logged (warn),
+// or the validation is skipped (off).
+func validateConfig(cfg Config, config []byte, documentLoader ld.DocumentLoader) error {
+	switch cfg.ConfigValidation {
+	case "off":
+		return nil
+	case "error", "warn":
+	default:
+		return errors.Errorf("unsupported CONFIG_VALIDATION '%s'", cfg.ConfigValidation)
+	}
+
+	var opts []lint.Option
+	if cfg.ConfigValidationJSONLD {
+		opts = append(opts, lint.WithDocumentLoader(documentLoader))
+	}
+	report, err := lint.LintBytes(cfg.HTTPConfigPath, config, opts...)
+	if err != nil {
+		return err
+	}
+	if len(report.Issues) == 0 {
+		return nil
+	}
+	if report.HasErrors() && cfg.ConfigValidation == "error" {
+		return errors.New(report.String())
+	}
+	logger.DefaultLogger.Warn(report.String())
+	return nil
+}
+
+// warnMemoryRefreshCounter warns about maxRefreshes policies without STORAGE_URL:
+// refreshes are counted in memory, so the limits are reset on restart.
+func warnMemoryRefreshCounter(registry *providers.Registry) {
+	for _, credentialType := range registry.Types() {
+		_, settings, err := registry.Get(credentialType)
+		if err == nil && settings.Policy.MaxRefreshes > 0 {
+			logger.DefaultLogger.Warnf("maxRefreshes of '%s' is counted in memory and reset on restart, "+
+				"set STORAGE_URL to keep refresh counts", credentialType)
+		}
+	}
+}
+
+// initProviders registers data providers from the content of the configuration file.
+// Chains are registered last, since they reference other providers.
+func initProviders(config []byte, callers map[int]ethereum.ContractCaller) (*providers.Registry, error) {
+	flexhttp, err := flexiblehttp.NewFactoryFlexibleHTTPFromBytes(config, nil)
+	if err != nil {
+		return nil, errors.Errorf("failed init flexiblehttp: %v", err)
+	}
+	onchainFactory, err := onchain.NewFactoryOnchainFromBytes(config, callers)
+	if err != nil {
+		return nil, errors.Errorf("failed init onchain: %v", err)
+	}
+	chainFactory, err := chain.NewFactoryChainFromBytes(config)
+	if err != nil {
+		return nil, errors.Errorf("failed init chain: %v", err)
+	}
+
+	registry := providers.NewRegistry()
+	if err := flexhttp.Register(registry); err != nil {
+		return nil, errors.Errorf("failed register flexiblehttp providers: %v", err)
+	}
+	if err := onchainFactory.Register(registry); err != nil {
+		return nil, errors.Errorf("failed register onchain providers: %v", err)
+	}
+	if err := chainFactory.Register(registry); err != nil {
+		return nil, errors.Errorf("failed register chain providers: %v", err)
+	}
+	return registry, nil
+}
+
 func initDocumentLoaderWithCache(ipfsGW string) (ld.DocumentLoader, error) {
 	opts := loaders.WithEmbeddedDocumentBytes(
 		w3cSchemaURL, w3cSchemaBody,
@@ -140,3 +300,19 @@ func initDocumentLoaderWithCache(ipfsGW string) (ld.DocumentLoader, error) {
 	l := loaders.NewDocumentLoader(nil, ipfsGW, loaders.WithCacheEngine(memoryCacheEngine))
 	return l, nil
 }
+
+func initContractCallers(supportedRPC map[string]string) (map[int]ethereum.ContractCaller, error) {
+	callers := make(map[int]ethereum.ContractCaller, len(supportedRPC))
+	for chainID, rpcURL := range supportedRPC {
+		id, err := strconv.Atoi(chainID)
+		if err != nil {
+			return nil, errors.Errorf("invalid chainID '%s': %v", chainID, err)
+		}
+		ec, err := ethclient.Dial(rpcURL)
+		if err != nil {
+			return nil, err
+		}
+		callers[id] = ec
+	}
+	return callers, nil
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache stores data provider results by key.
+type Cache interface {
+	// Get returns the value if it is present and not expired.
+	Get(key string) (interface{}, bool)
+	// Set stores the value for the ttl.
+	Set(key string, value interface{}, ttl time.Duration)
+}
+
+type item struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// Memory is an in-memory cache. When the cache is full,
+// the least recently used entry is evicted.
+type Memory struct {
+	mu         sync.Mutex
+	maxEntries int
+	items      map[string]*list.Element
+	lru        *list.List
+	now        func() time.Time
+}
+
+// NewMemory creates an in-memory cache with at most maxEntries entries.
+// A non-positive maxEntries means no limit.
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{
+		maxEntries: maxEntries,
+		items:      make(map[string]*list.Element),
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+func (m *Memory) Get(key string) (interface{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	it := e.Value.(*item)
+	if !m.now().Before(it.expiresAt) {
+		m.remove(e)
+		return nil, false
+	}
+	m.lru.MoveToFront(e)
+	return it.value, true
+}
+
+func (m *Memory) Set(key string, value interface{}, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expiresAt := m.now().Add(ttl)
+	if e, ok := m.items[key]; ok {
+		it := e.Value.(*item)
+		it.value = value
+		it.expiresAt = expiresAt
+		m.lru.MoveToFront(e)
+		return
+	}
+	m.items[key] = m.lru.PushFront(&item{key: key, value: value, expiresAt: expiresAt})
+	for m.maxEntries > 0 && m.lru.Len() > m.maxEntries {
+		m.remove(m.lru.Back())
+	}
+}
+
+// Len returns the number of entries, including expired ones that are not evicted yet.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+func (m *Memory) remove(e *list.Element) {
+	m.lru.Remove(e)
+	delete(m.items, e.Value.(*item).key)
+}
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	now := time.Now()
+	m := NewMemory(2)
+	m.now = func() time.Time { return now }
+
+	m.Set("a", 1, time.Minute)
+	m.Set("b", 2, time.Second)
+	v, ok := m.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+
+	// 'b' is the least recently used entry
+	m.Set("c", 3, time.Minute)
+	require.Equal(t, 2, m.Len())
+	_, ok = m.Get("b")
+	require.False(t, ok)
+
+	m.Set("a", 4, time.Second)
+	v, ok = m.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 4, v)
+
+	now = now.Add(time.Second)
+	_, ok = m.Get("a")
+	require.False(t, ok, "entry should expire")
+	v, ok = m.Get("c")
+	require.True(t, ok)
+	require.Equal(t, 3, v)
+	require.Equal(t, 1, m.Len())
+}
+
+func TestMemory_Unbounded(t *testing.T) {
+	m := NewMemory(0)
+	for _, k := range []string{"a", "b", "c"} {
+		m.Set(k, k, time.Minute)
+	}
+	require.Equal(t, 3, m.Len())
+}
@@ -0,0 +1,250 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// ProviderType is the value of provider.type for chains of data providers.
+const ProviderType = "chain"
+
+// Modes of a chain.
+const (
+	ModeFallback = "fallback"
+	ModeMerge    = "merge"
+	ModeMajority = "majority"
+	ModeMin      = "min"
+	ModeMax      = "max"
+)
+
+func isSupportedMode(mode string) bool {
+	switch mode {
+	case ModeFallback, ModeMerge, ModeMajority, ModeMin, ModeMax:
+		return true
+	default:
+		return false
+	}
+}
+
+// Fallback queries providers in order and returns the fields of the first provider that succeeds.
+// If all providers fail, the error of the last provider is returned.
+type Fallback []providers.Provider
+
+func (f Fallback) String() string {
+	return describe(ModeFallback, f)
+}
+
+func (f Fallback) Provide(ctx context.Context, credential *verifiable.W3CCredential) (map[string]interface{}, error) {
+	if len(f) == 0 {
+		return nil, errors.Wrap(flexiblehttp.ErrDataProviderIssue, "no providers in the chain")
+	}
+	var err error
+	for i, p := range f {
+		var fields map[string]interface{}
+		fields, err = p.Provide(ctx, credential)
+		if err == nil {
+			return fields, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if i < len(f)-1 {
+			logger.DefaultLogger.Warnf("provider #%d failed, fallback to the next one: %v", i, err)
+		}
+	}
+	return nil, err
+}
+
+// Aggregate queries all providers concurrently and reconciles their fields:
+//   - merge: fields of all providers are merged, earlier providers take precedence;
+//   - majority: a field gets the value returned by more than half of the providers;
+//   - min, max: a field gets the minimal or maximal numeric value.
+type Aggregate struct {
+	Mode      string
+	Providers []providers.Provider
+	// Quorum is the minimal number of providers that must succeed. The default is 1.
+	Quorum int
+}
+
+type result struct {
+	fields map[string]interface{}
+	err    error
+}
+
+func (a Aggregate) String() string {
+	return describe(a.Mode, a.Providers)
+}
+
+func (a Aggregate) Provide(ctx context.Context, credential *verifiable.W3CCredential) (map[string]interface{}, error) {
+	results := make([]result, len(a.Providers))
+	wg := sync.WaitGroup{}
+	for i, p := range a.Providers {
+		wg.Add(1)
+		go func(i int, p providers.Provider) {
+			defer wg.Done()
+			fields, err := p.Provide(ctx, credential)
+			results[i] = result{fields: fields, err: err}
+		}(i, p)
+	}
+	wg.Wait()
+
+	succeeded := make([]map[string]interface{}, 0, len(results))
+	var lastErr error
+	for i, r := range results {
+		if r.err != nil {
+			logger.DefaultLogger.Warnf("provider #%d failed: %v", i, r.err)
+			lastErr = r.err
+			continue
+		}
+		succeeded = append(succeeded, r.fields)
+	}
+	quorum := a.Quorum
+	if quorum < 1 {
+		quorum = 1
+	}
+	if len(succeeded) < quorum {
+		if lastErr == nil {
+			lastErr = flexiblehttp.ErrDataProviderIssue
+		}
+		return nil, errors.Wrapf(lastErr, "%d of %d providers succeeded, quorum is %d",
+			len(succeeded), len(a.Providers), quorum)
+	}
+
+	switch a.Mode {
+	case ModeMerge:
+		return merge(succeeded), nil
+	case ModeMajority:
+		return majority(succeeded, len(a.Providers))
+	case ModeMin:
+		return extremum(succeeded, -1)
+	case ModeMax:
+		return extremum(succeeded, 1)
+	default:
+		return nil, errors.Wrapf(flexiblehttp.ErrInvalidResponseSchema, "unsupported aggregation mode '%s'", a.Mode)
+	}
+}
+
+func describe(mode string, chain []providers.Provider) string {
+	names := make([]string, len(chain))
+	for i, p := range chain {
+		names[i] = providers.Describe(p)
+	}
+	return fmt.Sprintf("chain %s(%s)", mode, strings.Join(names, ", "))
+}
+
+func merge(results []map[string]interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for i := len(results) - 1; i >= 0; i-- {
+		for k, v := range results[i] {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+func fieldNames(results []map[string]interface{}) []string {
+	names := make(map[string]struct{})
+	for _, r := range results {
+		for k := range r {
+			names[k] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for k := range names {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// majority picks for every field the value returned by more than half of total providers.
+// Values are compared by their string representation, so 42 and 42.0 are equal.
+func majority(results []map[string]interface{}, total int) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	for _, name := range fieldNames(results) {
+		votes := make(map[string]int)
+		values := make(map[string]interface{})
+		for _, r := range results {
+			v, ok := r[name]
+			if !ok {
+				continue
+			}
+			key := fmt.Sprint(v)
+			votes[key]++
+			if _, ok := values[key]; !ok {
+				values[key] = v
+			}
+		}
+		found := false
+		for key, n := range votes {
+			if n*2 > total {
+				fields[name] = values[key]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Wrapf(flexiblehttp.ErrDataProviderIssue,
+				"providers don't agree on the value of field '%s'", name)
+		}
+	}
+	return fields, nil
+}
+
+// extremum picks for every field the minimal (sign -1) or maximal (sign 1) numeric value.
+func extremum(results []map[string]interface{}, sign int) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	for _, name := range fieldNames(results) {
+		var best *big.Float
+		for _, r := range results {
+			v, ok := r[name]
+			if !ok {
+				continue
+			}
+			n, err := toBigFloat(v)
+			if err != nil {
+				return nil, errors.Wrapf(flexiblehttp.ErrInvalidResponseSchema, "field '%s': %v", name, err)
+			}
+			if best == nil || n.Cmp(best) == sign {
+				best = n
+				fields[name] = v
+			}
+		}
+	}
+	return fields, nil
+}
+
+// floatPrecision is enough to compare 256-bit integers exactly.
+const floatPrecision = 512
+
+func toBigFloat(v interface{}) (*big.Float, error) {
+	n := new(big.Float).SetPrec(floatPrecision)
+	switch v := v.(type) {
+	case int:
+		return n.SetInt64(int64(v)), nil
+	case int64:
+		return n.SetInt64(v), nil
+	case float64:
+		return n.SetFloat64(v), nil
+	case *big.Int:
+		return n.SetInt(v), nil
+	case string:
+		n, ok := n.SetString(v)
+		if !ok {
+			return nil, errors.Errorf("'%s' is not a number", v)
+		}
+		return n, nil
+	default:
+		return nil, errors.Errorf("'%v' of type '%T' is not a number", v, v)
+	}
+}
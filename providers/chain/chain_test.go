@@ -0,0 +1,197 @@
+package chain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+var errProviderDown = errors.Wrap(flexiblehttp.ErrDataProviderIssue, "provider is down")
+
+func failing() providers.Provider {
+	return providers.ProviderFunc(func(_ context.Context, _ *verifiable.W3CCredential) (map[string]interface{}, error) {
+		return nil, errProviderDown
+	})
+}
+
+func TestFallback(t *testing.T) {
+	tests := []struct {
+		name          string
+		chain         Fallback
+		expected      map[string]interface{}
+		expectedError error
+	}{
+		{
+			name:     "First provider succeeds",
+			chain:    Fallback{providers.Static{"balance": 1}, providers.Static{"balance": 2}},
+			expected: map[string]interface{}{"balance": 1},
+		},
+		{
+			name:     "Fallback to the second provider",
+			chain:    Fallback{failing(), providers.Static{"balance": 2}},
+			expected: map[string]interface{}{"balance": 2},
+		},
+		{
+			name:          "All providers fail",
+			chain:         Fallback{failing(), failing()},
+			expectedError: flexiblehttp.ErrDataProviderIssue,
+		},
+		{
+			name:          "Empty chain",
+			chain:         Fallback{},
+			expectedError: flexiblehttp.ErrDataProviderIssue,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := tt.chain.Provide(context.Background(), &verifiable.W3CCredential{})
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, fields)
+		})
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		name          string
+		aggregate     Aggregate
+		expected      map[string]interface{}
+		expectedError error
+	}{
+		{
+			name: "Merge, earlier providers take precedence",
+			aggregate: Aggregate{Mode: ModeMerge, Providers: []providers.Provider{
+				providers.Static{"balance": 1},
+				failing(),
+				providers.Static{"balance": 2, "currency": "MATIC"},
+			}},
+			expected: map[string]interface{}{"balance": 1, "currency": "MATIC"},
+		},
+		{
+			name: "Merge below quorum",
+			aggregate: Aggregate{Mode: ModeMerge, Quorum: 2, Providers: []providers.Provider{
+				providers.Static{"balance": 1},
+				failing(),
+			}},
+			expectedError: flexiblehttp.ErrDataProviderIssue,
+		},
+		{
+			name: "Majority",
+			aggregate: Aggregate{Mode: ModeMajority, Providers: []providers.Provider{
+				providers.Static{"balance": 1, "currency": "MATIC"},
+				providers.Static{"balance": 2.0, "currency": "MATIC"},
+				providers.Static{"balance": 2, "currency": "ETH"},
+			}},
+			expected: map[string]interface{}{"balance": 2.0, "currency": "MATIC"},
+		},
+		{
+			name: "Majority of all providers is required",
+			aggregate: Aggregate{Mode: ModeMajority, Providers: []providers.Provider{
+				providers.Static{"balance": 1},
+				providers.Static{"balance": 2},
+				failing(),
+			}},
+			expectedError: flexiblehttp.ErrDataProviderIssue,
+		},
+		{
+			name: "Min",
+			aggregate: Aggregate{Mode: ModeMin, Providers: []providers.Provider{
+				providers.Static{"balance": "1000000000000000000000", "price": 1.5},
+				providers.Static{"balance": "999999999999999999999", "price": 2},
+			}},
+			expected: map[string]interface{}{"balance": "999999999999999999999", "price": 1.5},
+		},
+		{
+			name: "Max",
+			aggregate: Aggregate{Mode: ModeMax, Providers: []providers.Provider{
+				providers.Static{"balance": 3},
+				providers.Static{"balance": 10.5},
+				failing(),
+			}},
+			expected: map[string]interface{}{"balance": 10.5},
+		},
+		{
+			name: "Max of non-numeric values",
+			aggregate: Aggregate{Mode: ModeMax, Providers: []providers.Provider{
+				providers.Static{"currency": "MATIC"},
+			}},
+			expectedError: flexiblehttp.ErrInvalidResponseSchema,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := tt.aggregate.Provide(context.Background(), &verifiable.W3CCredential{})
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, fields)
+		})
+	}
+}
+
+func TestFactoryChain(t *testing.T) {
+	factory, err := NewFactoryChain("./testvectors/chain.yaml")
+	require.NoError(t, err)
+
+	registry := providers.NewRegistry()
+	require.NoError(t, registry.Register("primary", failing(), providers.Settings{}))
+	require.NoError(t, registry.Register("secondary", providers.Static{"balance": 2}, providers.Settings{}))
+	require.NoError(t, registry.Register("tertiary", providers.Static{"balance": 3}, providers.Settings{}))
+	require.NoError(t, factory.Register(registry))
+
+	tests := []struct {
+		credentialType string
+		expected       map[string]interface{}
+		expectedError  error
+	}{
+		{credentialType: "urn:test:Balance", expected: map[string]interface{}{"balance": 2}},
+		{credentialType: "urn:test:Consensus", expectedError: flexiblehttp.ErrDataProviderIssue},
+		{credentialType: "urn:test:Nested", expected: map[string]interface{}{"balance": 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.credentialType, func(t *testing.T) {
+			p, _, err := registry.Get(tt.credentialType)
+			require.NoError(t, err)
+			fields, err := p.Provide(context.Background(), &verifiable.W3CCredential{})
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, fields)
+		})
+	}
+
+	_, settings, err := registry.Get("urn:test:Balance")
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, settings.TimeExpiration)
+}
+
+func TestFactoryChain_Error(t *testing.T) {
+	factory, err := NewFactoryChain("./testvectors/chain.yaml")
+	require.NoError(t, err)
+	err = factory.Register(providers.NewRegistry())
+	require.ErrorContains(t, err, "urn:test:Balance, urn:test:Consensus, urn:test:Nested")
+}
+
+func TestDescribe(t *testing.T) {
+	chain := Fallback{
+		providers.Static{},
+		Aggregate{Mode: ModeMajority, Providers: []providers.Provider{providers.Static{}, failing()}},
+	}
+	require.Equal(t,
+		"chain fallback(providers.Static, chain majority(providers.Static, providers.ProviderFunc))",
+		providers.Describe(chain))
+}
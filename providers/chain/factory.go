@@ -0,0 +1,147 @@
+package chain
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+type provider struct {
+	Type string `yaml:"type"`
+}
+
+type chainConfig struct {
+	// Mode is fallback (default), merge, majority, min or max.
+	Mode string `yaml:"mode"`
+	// Providers are keys of other entries of the configuration file.
+	Providers []string `yaml:"providers"`
+	Quorum    int      `yaml:"quorum"`
+}
+
+type entry struct {
+	Settings providers.Settings `yaml:"settings"`
+	Provider provider           `yaml:"provider"`
+	Chain    chainConfig        `yaml:"chain"`
+}
+
+type FactoryChain struct {
+	configuration map[string]entry
+}
+
+// NewFactoryChain reads entries with the 'chain' provider type from the configuration file.
+func NewFactoryChain(configPath string) (FactoryChain, error) {
+	//nolint:gosec // configPath is a constant path in the project
+	f, err := os.ReadFile(configPath)
+	if err != nil {
+		return FactoryChain{}, err
+	}
+	return NewFactoryChainFromBytes(f)
+}
+
+// NewFactoryChainFromBytes is like NewFactoryChain, but takes the content of the configuration file.
+func NewFactoryChainFromBytes(f []byte) (FactoryChain, error) {
+	cfgs := make(map[string]entry)
+	if err := yaml.Unmarshal(f, &cfgs); err != nil {
+		return FactoryChain{}, err
+	}
+	for credentialType, cfg := range cfgs {
+		if cfg.Provider.Type != ProviderType {
+			delete(cfgs, credentialType)
+			continue
+		}
+		if cfg.Chain.Mode == "" {
+			cfg.Chain.Mode = ModeFallback
+			cfgs[credentialType] = cfg
+		}
+		if !isSupportedMode(cfg.Chain.Mode) {
+			return FactoryChain{}, errors.Errorf("unsupported chain mode '%s' for '%s'",
+				cfg.Chain.Mode, credentialType)
+		}
+		if len(cfg.Chain.Providers) == 0 {
+			return FactoryChain{}, errors.Errorf("no providers in the chain for '%s'", credentialType)
+		}
+	}
+	return FactoryChain{
+		configuration: cfgs,
+	}, nil
+}
+
+// Register adds all configured chains to the registry. Providers of a chain are
+// looked up in the registry, so Register must be called after other factories.
+// A chain can reference another chain.
+func (factory *FactoryChain) Register(registry *providers.Registry) error {
+	pending := make(map[string]entry, len(factory.configuration))
+	for credentialType, cfg := range factory.configuration {
+		pending[credentialType] = cfg
+	}
+	for len(pending) > 0 {
+		registered := 0
+		for credentialType, cfg := range pending {
+			chained, ok := resolve(registry, cfg.Chain.Providers)
+			if !ok {
+				continue
+			}
+			var p providers.Provider = Fallback(chained)
+			if cfg.Chain.Mode != ModeFallback {
+				p = Aggregate{Mode: cfg.Chain.Mode, Providers: chained, Quorum: cfg.Chain.Quorum}
+			}
+			if err := registry.Register(credentialType, p, cfg.Settings); err != nil {
+				return err
+			}
+			delete(pending, credentialType)
+			registered++
+		}
+		if registered == 0 {
+			unresolved := make([]string, 0, len(pending))
+			for credentialType := range pending {
+				unresolved = append(unresolved, credentialType)
+			}
+			sort.Strings(unresolved)
+			return errors.Errorf("chains reference unknown providers or each other in a cycle: %s",
+				strings.Join(unresolved, ", "))
+		}
+	}
+	return nil
+}
+
+func resolve(registry *providers.Registry, names []string) ([]providers.Provider, bool) {
+	chained := make([]providers.Provider, 0, len(names))
+	for _, name := range names {
+		p, _, err := registry.Get(name)
+		if err != nil {
+			return nil, false
+		}
+		chained = append(chained, p)
+	}
+	return chained, true
+}
+
+// Lint checks the chain entry of the configuration file. defined reports whether
+// a credential type is defined in the file. Chains can reference providers
+// registered in code, so undefined references are returned as warnings.
+func Lint(node *yaml.Node, defined func(credentialType string) bool) (issues, warnings []error) {
+	var cfg entry
+	if err := node.Decode(&cfg); err != nil {
+		return []error{err}, nil
+	}
+	if cfg.Chain.Mode != "" && !isSupportedMode(cfg.Chain.Mode) {
+		issues = append(issues, errors.Errorf("unsupported chain mode '%s'", cfg.Chain.Mode))
+	}
+	if len(cfg.Chain.Providers) == 0 {
+		issues = append(issues, errors.New("no providers in the chain"))
+	}
+	if cfg.Chain.Quorum > len(cfg.Chain.Providers) {
+		issues = append(issues, errors.Errorf("quorum %d is greater than the number of providers",
+			cfg.Chain.Quorum))
+	}
+	for _, name := range cfg.Chain.Providers {
+		if !defined(name) {
+			warnings = append(warnings, errors.Errorf("provider '%s' is not defined in the configuration file", name))
+		}
+	}
+	return issues, warnings
+}
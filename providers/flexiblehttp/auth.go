@@ -0,0 +1,230 @@
+package flexiblehttp
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	authTypeOAuth2 = "oauth2"
+	authTypeBearer = "bearer"
+	authTypeBasic  = "basic"
+	authTypeAPIKey = "apikey"
+
+	apiKeyInHeader = "header"
+	apiKeyInQuery  = "query"
+)
+
+// secretRef is a reference to a secret value. In the configuration it can be
+// a plain string or an object with 'env' or 'file' key:
+//
+//	clientSecret:
+//	  env: PROVIDER_CLIENT_SECRET
+type secretRef struct {
+	Value string `yaml:"value"`
+	Env   string `yaml:"env"`
+	File  string `yaml:"file"`
+}
+
+func (s *secretRef) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		s.Value = node.Value
+		return nil
+	}
+	type plain secretRef
+	return node.Decode((*plain)(s))
+}
+
+func (s *secretRef) resolve() (string, error) {
+	switch {
+	case s == nil:
+		return "", nil
+	case s.Env != "":
+		if err := checkEnvName(s.Env); err != nil {
+			return "", err
+		}
+		v, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", errors.Errorf("environment variable '%s' is not set", s.Env)
+		}
+		return v, nil
+	case s.File != "":
+		//nolint:gosec // the path is defined by the operator of the service
+		b, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", errors.Errorf("failed to read secret file: %v", err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	default:
+		return s.Value, nil
+	}
+}
+
+// validate checks that the referenced environment variable is allowed.
+func (s *secretRef) validate() error {
+	if s == nil || s.Env == "" {
+		return nil
+	}
+	return checkEnvName(s.Env)
+}
+
+// validateSecrets validates secret references by their names in the configuration.
+func validateSecrets(refs map[string]*secretRef) error {
+	for _, name := range sortedKeys(refs) {
+		if err := refs[name].validate(); err != nil {
+			return errors.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+type authConfig struct {
+	Type string `yaml:"type"`
+
+	// oauth2 client credentials
+	TokenURL       string            `yaml:"tokenURL"`
+	ClientID       *secretRef        `yaml:"clientID"`
+	ClientSecret   *secretRef        `yaml:"clientSecret"`
+	Scopes         []string          `yaml:"scopes"`
+	EndpointParams map[string]string `yaml:"endpointParams"`
+
+	// bearer
+	Token *secretRef `yaml:"token"`
+
+	// basic
+	Username *secretRef `yaml:"username"`
+	Password *secretRef `yaml:"password"`
+
+	// apikey
+	Name  string     `yaml:"name"`
+	In    string     `yaml:"in"`
+	Value *secretRef `yaml:"value"`
+
+	once         sync.Once
+	oauth2Config *clientcredentials.Config
+	oauth2Err    error
+	tokenMu      sync.Mutex
+	token        *oauth2.Token
+}
+
+// validate checks the secret references of the auth section.
+func (a *authConfig) validate() error {
+	return validateSecrets(map[string]*secretRef{
+		"clientID":     a.ClientID,
+		"clientSecret": a.ClientSecret,
+		"token":        a.Token,
+		"username":     a.Username,
+		"password":     a.Password,
+		"value":        a.Value,
+	})
+}
+
+// oauth2ClientCredentials returns the client credentials configuration of the auth section.
+func (a *authConfig) oauth2ClientCredentials() (*clientcredentials.Config, error) {
+	a.once.Do(func() {
+		clientID, err := a.ClientID.resolve()
+		if err != nil {
+			a.oauth2Err = errors.Errorf("client id: %v", err)
+			return
+		}
+		clientSecret, err := a.ClientSecret.resolve()
+		if err != nil {
+			a.oauth2Err = errors.Errorf("client secret: %v", err)
+			return
+		}
+		params := make(map[string][]string, len(a.EndpointParams))
+		for k, v := range a.EndpointParams {
+			params[k] = []string{v}
+		}
+		a.oauth2Config = &clientcredentials.Config{
+			ClientID:       clientID,
+			ClientSecret:   clientSecret,
+			TokenURL:       a.TokenURL,
+			Scopes:         a.Scopes,
+			EndpointParams: params,
+		}
+	})
+	return a.oauth2Config, a.oauth2Err
+}
+
+// oauth2Token returns the cached token and requests a new one when it expires.
+// The token is requested within the deadline of the context.
+func (a *authConfig) oauth2Token(ctx context.Context, httpcli *http.Client) (*oauth2.Token, error) {
+	a.tokenMu.Lock()
+	defer a.tokenMu.Unlock()
+	if a.token.Valid() {
+		return a.token, nil
+	}
+	cfg, err := a.oauth2ClientCredentials()
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidRequestSchema, err.Error())
+	}
+	token, err := cfg.Token(context.WithValue(ctx, oauth2.HTTPClient, httpcli))
+	if err != nil {
+		return nil, errors.Wrapf(ErrDataProviderIssue, "failed to get oauth2 token: %v", err)
+	}
+	a.token = token
+	return token, nil
+}
+
+// Authorize adds credentials from the auth section to the request.
+// Failures to obtain an OAuth2 token are reported as ErrDataProviderIssue.
+// Authorize is called by BuildRequest before the request is signed. The OAuth2 token
+// is requested within the deadline of the request context.
+func (fh *FlexibleHTTP) Authorize(request *http.Request) error {
+	a := fh.Auth
+	if a == nil {
+		return nil
+	}
+
+	switch a.Type {
+	case authTypeOAuth2:
+		token, err := a.oauth2Token(request.Context(), fh.httpcli)
+		if err != nil {
+			return err
+		}
+		token.SetAuthHeader(request)
+	case authTypeBearer:
+		token, err := a.Token.resolve()
+		if err != nil {
+			return errors.Wrapf(ErrInvalidRequestSchema, "bearer token: %v", err)
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	case authTypeBasic:
+		username, err := a.Username.resolve()
+		if err != nil {
+			return errors.Wrapf(ErrInvalidRequestSchema, "basic auth username: %v", err)
+		}
+		password, err := a.Password.resolve()
+		if err != nil {
+			return errors.Wrapf(ErrInvalidRequestSchema, "basic auth password: %v", err)
+		}
+		request.SetBasicAuth(username, password)
+	case authTypeAPIKey:
+		value, err := a.Value.resolve()
+		if err != nil {
+			return errors.Wrapf(ErrInvalidRequestSchema, "api key: %v", err)
+		}
+		switch a.In {
+		case apiKeyInHeader, "":
+			request.Header.Set(a.Name, value)
+		case apiKeyInQuery:
+			q := request.URL.Query()
+			q.Set(a.Name, value)
+			request.URL.RawQuery = q.Encode()
+		default:
+			return errors.Wrapf(ErrInvalidRequestSchema, "unsupported api key location '%s'", a.In)
+		}
+	default:
+		return errors.Wrapf(ErrInvalidRequestSchema, "unsupported auth type '%s'", a.Type)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package flexiblehttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	t.Setenv("PROVIDER_TEST_BASIC_PASSWORD", "pass")
+	t.Setenv("PROVIDER_TEST_API_KEY", "key")
+	tests := []struct {
+		name           string
+		credentialType string
+		expectedHeader http.Header
+	}{
+		{
+			name:           "Bearer token from file",
+			credentialType: "urn:test:Bearer",
+			expectedHeader: http.Header{"Authorization": {"Bearer file-token"}},
+		},
+		{
+			name:           "Basic auth",
+			credentialType: "urn:test:Basic",
+			expectedHeader: http.Header{"Authorization": {"Basic cmVmcmVzaDpwYXNz"}},
+		},
+		{
+			name:           "API key in header",
+			credentialType: "urn:test:APIKeyHeader",
+			expectedHeader: http.Header{"X-Api-Key": {"key"}},
+		},
+	}
+
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/auth.yaml", nil)
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := factory.ProduceFlexibleHTTP(tt.credentialType)
+			require.NoError(t, err)
+			request, err := provider.BuildRequest(context.Background(), TemplateData{})
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedHeader, request.Header)
+		})
+	}
+}
+
+func TestAuthorize_OAuth2(t *testing.T) {
+	t.Setenv("PROVIDER_TEST_OAUTH2_CLIENT_SECRET", "client-secret")
+	tokenRequests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+		require.Equal(t, "balance:read", r.Form.Get("scope"))
+		user, pass, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "refresh-service", user)
+		require.Equal(t, "client-secret", pass)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "access-token", "token_type": "Bearer", "expires_in": 3600}`))
+	}))
+	defer srv.Close()
+
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/auth.yaml", srv.Client())
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		provider, err := factory.ProduceFlexibleHTTP("urn:test:OAuth2")
+		require.NoError(t, err)
+		provider.Auth.TokenURL = srv.URL
+		request, err := provider.BuildRequest(context.Background(), TemplateData{})
+		require.NoError(t, err)
+		require.Equal(t, "Bearer access-token", request.Header.Get("Authorization"))
+	}
+	require.Equal(t, 1, tokenRequests, "token should be cached")
+}
+
+func TestAuthorize_OAuth2Deadline(t *testing.T) {
+	t.Setenv("PROVIDER_TEST_OAUTH2_CLIENT_SECRET", "client-secret")
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/auth.yaml", srv.Client())
+	require.NoError(t, err)
+	provider, err := factory.ProduceFlexibleHTTP("urn:test:OAuth2")
+	require.NoError(t, err)
+	provider.Auth.TokenURL = srv.URL
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = provider.BuildRequest(ctx, TemplateData{})
+	require.ErrorIs(t, err, ErrDataProviderIssue)
+	require.ErrorContains(t, err, context.DeadlineExceeded.Error())
+}
+
+func TestAuthorize_Error(t *testing.T) {
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/auth.yaml", nil)
+	require.NoError(t, err)
+	provider, err := factory.ProduceFlexibleHTTP("urn:test:Basic")
+	require.NoError(t, err)
+	_, err = provider.BuildRequest(context.Background(), TemplateData{})
+	require.ErrorIs(t, err, ErrInvalidRequestSchema)
+}
+
+func TestNewFactoryFlexibleHTTP_ServiceSecret(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        string
+		expectedError string
+	}{
+		{
+			name: "Auth",
+			config: `urn:test:Bearer:
+  provider:
+    url: https://bank.example.com/balance
+  auth:
+    type: bearer
+    token:
+      env: ISSUERS_BASIC_AUTH`,
+			expectedError: "invalid auth for 'urn:test:Bearer': token: environment variable 'ISSUERS_BASIC_AUTH' is not allowed",
+		},
+		{
+			name: "Signing",
+			config: `urn:test:HMAC:
+  provider:
+    url: https://bank.example.com/balance
+  signing:
+    scheme: hmac-sha256
+    secret:
+      env: ADMIN_TOKEN`,
+			expectedError: "invalid signing for 'urn:test:HMAC': secret: environment variable 'ADMIN_TOKEN' is not allowed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFactoryFlexibleHTTPFromBytes([]byte(tt.config), nil)
+			require.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+
+	// references are checked when they are resolved too
+	t.Setenv("ISSUERS_BASIC_AUTH", "user:pass")
+	_, err := (&secretRef{Env: "ISSUERS_BASIC_AUTH"}).resolve()
+	require.ErrorContains(t, err, "the name must start with 'PROVIDER_'")
+}
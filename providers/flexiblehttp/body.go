@@ -0,0 +1,112 @@
+package flexiblehttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	bodyTypeJSON = "json"
+	bodyTypeForm = "form"
+	bodyTypeText = "text"
+)
+
+type requestBody struct {
+	Type    string      `yaml:"type"`
+	Content interface{} `yaml:"content"`
+}
+
+func (rb *requestBody) isEmpty() bool {
+	return rb == nil || rb.Content == nil
+}
+
+func (rb *requestBody) contentType() string {
+	switch rb.Type {
+	case bodyTypeForm:
+		return "application/x-www-form-urlencoded"
+	case bodyTypeText:
+		return "text/plain"
+	default:
+		return "application/json"
+	}
+}
+
+func (rb *requestBody) build(data TemplateData) ([]byte, error) {
+	if rb.isEmpty() {
+		return nil, nil
+	}
+
+	switch rb.Type {
+	case bodyTypeJSON, "":
+		content, err := fillJSONBody(rb.Content, data)
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(content)
+		if err != nil {
+			return nil, errors.Errorf("failed to marshal json body: %v", err)
+		}
+		return b, nil
+	case bodyTypeForm:
+		fields, ok := rb.Content.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("form body should be a key-value list")
+		}
+		form := url.Values{}
+		for k, v := range fields {
+			value, err := data.Render(fmt.Sprintf("%v", v))
+			if err != nil {
+				return nil, err
+			}
+			form.Add(k, value)
+		}
+		return []byte(form.Encode()), nil
+	case bodyTypeText:
+		text, ok := rb.Content.(string)
+		if !ok {
+			return nil, errors.New("text body should be a string")
+		}
+		value, err := data.Render(text)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(value), nil
+	default:
+		return nil, errors.Errorf("unsupported body type '%s'", rb.Type)
+	}
+}
+
+// fillJSONBody walks the body template and executes every string as a template.
+// A string that consists of a single action is replaced by the raw value,
+// so numbers and booleans keep their JSON type.
+func fillJSONBody(content interface{}, data TemplateData) (interface{}, error) {
+	switch v := content.(type) {
+	case map[string]interface{}:
+		filled := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			f, err := fillJSONBody(value, data)
+			if err != nil {
+				return nil, err
+			}
+			filled[key] = f
+		}
+		return filled, nil
+	case []interface{}:
+		filled := make([]interface{}, 0, len(v))
+		for _, value := range v {
+			f, err := fillJSONBody(value, data)
+			if err != nil {
+				return nil, err
+			}
+			filled = append(filled, f)
+		}
+		return filled, nil
+	case string:
+		return data.Value(v)
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,110 @@
+package flexiblehttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers/cache"
+	"github.com/pkg/errors"
+)
+
+const defaultCacheMaxEntries = 1000
+
+// cacheConfig is the cache section of the provider configuration.
+// Results are cached by the built request before the auth section is applied.
+type cacheConfig struct {
+	TTL        time.Duration `yaml:"ttl"`
+	MaxEntries int           `yaml:"maxEntries"`
+	// ShareAcrossSubjects allows to return a result cached for a credential
+	// with a different subject, if the built requests are the same.
+	ShareAcrossSubjects bool `yaml:"shareAcrossSubjects"`
+
+	store cache.Cache
+}
+
+func (c *cacheConfig) validate() error {
+	if c.TTL <= 0 {
+		return errors.New("cache ttl should be positive")
+	}
+	return nil
+}
+
+func (c *cacheConfig) key(request *http.Request, body []byte, data TemplateData) (string, error) {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\n%s\n", request.Method, request.URL.String())
+	names := make([]string, 0, len(request.Header))
+	for name := range request.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range request.Header[name] {
+			_, _ = fmt.Fprintf(h, "%s: %s\n", name, v)
+		}
+	}
+	_, _ = fmt.Fprintf(h, "\n%d\n", len(body))
+	_, _ = h.Write(body)
+	if !c.ShareAcrossSubjects {
+		// map keys are sorted by encoding/json
+		subject, err := json.Marshal(data.CredentialSubject)
+		if err != nil {
+			return "", errors.Errorf("failed to marshal credential subject: %v", err)
+		}
+		_, _ = h.Write([]byte("\n"))
+		_, _ = h.Write(subject)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *cacheConfig) get(key string) (map[string]interface{}, bool) {
+	if c.store == nil {
+		return nil, false
+	}
+	v, ok := c.store.Get(key)
+	if !ok {
+		return nil, false
+	}
+	fields, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return copyFields(fields), true
+}
+
+func (c *cacheConfig) set(key string, fields map[string]interface{}) {
+	if c.store == nil {
+		return
+	}
+	c.store.Set(key, copyFields(fields), c.TTL)
+}
+
+// copyFields deep copies the fields, so the cached result can't be changed
+// by the caller that stored it or by callers that got it from the cache.
+func copyFields(fields map[string]interface{}) map[string]interface{} {
+	cp := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		cp[k] = copyValue(v)
+	}
+	return cp
+}
+
+// copyValue deep copies objects and arrays of decoded responses. Other values are immutable.
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return copyFields(v)
+	case []interface{}:
+		cp := make([]interface{}, len(v))
+		for i, item := range v {
+			cp[i] = copyValue(item)
+		}
+		return cp
+	default:
+		return v
+	}
+}
@@ -0,0 +1,122 @@
+package flexiblehttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers/cache"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvide_Cache(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte(`{"price": "` + r.URL.Query().Get("symbol") + `-price"}`))
+	}))
+	defer srv.Close()
+
+	credential := func(id, symbol string) *verifiable.W3CCredential {
+		return &verifiable.W3CCredential{
+			CredentialSubject: map[string]interface{}{"id": id, "symbol": symbol},
+		}
+	}
+	tests := []struct {
+		name             string
+		credentialType   string
+		credentials      []*verifiable.W3CCredential
+		expectedRequests int32
+	}{
+		{
+			name:           "Shared across subjects",
+			credentialType: "urn:test:Price",
+			credentials: []*verifiable.W3CCredential{
+				credential("did:iden3:alice", "MATIC"),
+				credential("did:iden3:bob", "MATIC"),
+				credential("did:iden3:bob", "ETH"),
+			},
+			expectedRequests: 2,
+		},
+		{
+			name:           "Not shared across subjects",
+			credentialType: "urn:test:Balance",
+			credentials: []*verifiable.W3CCredential{
+				credential("did:iden3:alice", "MATIC"),
+				credential("did:iden3:alice", "MATIC"),
+				credential("did:iden3:bob", "MATIC"),
+			},
+			expectedRequests: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&requests, 0)
+			factory, err := NewFactoryFlexibleHTTP("./testvectors/cache.yaml", srv.Client())
+			require.NoError(t, err)
+			for _, c := range tt.credentials {
+				fh, err := factory.ProduceFlexibleHTTP(tt.credentialType)
+				require.NoError(t, err)
+				fh.Provider.URL = srv.URL
+				fields, err := fh.Provide(context.Background(), c)
+				require.NoError(t, err)
+				require.Equal(t, map[string]interface{}{
+					"price": c.CredentialSubject["symbol"].(string) + "-price",
+				}, fields)
+			}
+			require.Equal(t, tt.expectedRequests, atomic.LoadInt32(&requests))
+		})
+	}
+}
+
+func TestCacheConfig_DeepCopy(t *testing.T) {
+	c := &cacheConfig{TTL: time.Minute, store: cache.NewMemory(10)}
+	fields := map[string]interface{}{
+		"balance": 100,
+		"tokens":  []interface{}{"MATIC", map[string]interface{}{"symbol": "ETH"}},
+		"owner":   map[string]interface{}{"address": "0x01", "tags": []interface{}{"vip"}},
+	}
+	expected := map[string]interface{}{
+		"balance": 100,
+		"tokens":  []interface{}{"MATIC", map[string]interface{}{"symbol": "ETH"}},
+		"owner":   map[string]interface{}{"address": "0x01", "tags": []interface{}{"vip"}},
+	}
+
+	c.set("key", fields)
+	// the caller that stored the result changes it
+	fields["tokens"].([]interface{})[0] = "changed"
+	fields["owner"].(map[string]interface{})["address"] = "changed"
+
+	cached, ok := c.get("key")
+	require.True(t, ok)
+	require.Equal(t, expected, cached)
+
+	// the caller that got the result changes it
+	cached["tokens"].([]interface{})[1].(map[string]interface{})["symbol"] = "changed"
+	cached["owner"].(map[string]interface{})["tags"].([]interface{})[0] = "changed"
+
+	cached, ok = c.get("key")
+	require.True(t, ok)
+	require.Equal(t, expected, cached)
+}
+
+func TestFactorySetCache(t *testing.T) {
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/cache.yaml", nil)
+	require.NoError(t, err)
+	store := cache.NewMemory(1)
+	require.NoError(t, factory.SetCache("urn:test:Price", store))
+
+	fh, err := factory.ProduceFlexibleHTTP("urn:test:Price")
+	require.NoError(t, err)
+	fh.Cache.set("key", map[string]interface{}{"price": "1"})
+	require.Equal(t, 1, store.Len())
+
+	factory, err = NewFactoryFlexibleHTTP("./testvectors/balance.yaml", nil)
+	require.NoError(t, err)
+	err = factory.SetCache("https://raw.githubusercontent.com/iden3/claim-schema-vocab/main/schemas/json-ld/balance.json-ld#Balance", store)
+	require.ErrorContains(t, err, "cache is not configured")
+}
@@ -0,0 +1,199 @@
+package flexiblehttp
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jmespath/go-jmespath"
+	"github.com/pkg/errors"
+)
+
+const (
+	responseTypeJSON = "json"
+	responseTypeXML  = "xml"
+	responseTypeCSV  = "csv"
+	responseTypeText = "text"
+)
+
+type csvOptions struct {
+	Delimiter string `yaml:"delimiter"`
+	// NoHeader means that the first row contains data,
+	// rows are decoded as arrays instead of objects.
+	NoHeader bool `yaml:"noHeader"`
+}
+
+// decode parses the provider response according to the response type.
+// json, xml and csv responses are converted to a JSON-like structure
+// that is queried with JMESPath, text responses are kept as is.
+// JSON integers that don't fit float64 are kept as json.Number to preserve precision.
+func (rs *ResponseSchema) decode(body io.Reader) (interface{}, error) {
+	switch rs.Type {
+	case responseTypeJSON, "":
+		var response interface{}
+		d := json.NewDecoder(body)
+		d.UseNumber()
+		if err := d.Decode(&response); err != nil {
+			return nil, err
+		}
+		return normalizeNumbers(response), nil
+	case responseTypeXML:
+		return decodeXML(body)
+	case responseTypeCSV:
+		return decodeCSV(body, rs.CSV)
+	case responseTypeText:
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	default:
+		return nil, errors.Errorf("unsupported response type '%s'", rs.Type)
+	}
+}
+
+// search extracts a value from the decoded response. For text responses
+// the expression is a regular expression, the value is the named group
+// 'value', the first group or the whole match. For other types the expression
+// is a JMESPath expression.
+func (rs *ResponseSchema) search(expression string, response interface{}) (interface{}, error) {
+	if rs.Type != responseTypeText {
+		v, err := jmespath.Search(expression, response)
+		if err != nil {
+			return nil, errors.Errorf("invalid expression '%s': %v", expression, err)
+		}
+		return v, nil
+	}
+
+	text, ok := response.(string)
+	if !ok {
+		return nil, errors.Errorf("text response expected, got '%T'", response)
+	}
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, errors.Errorf("invalid regular expression '%s': %v", expression, err)
+	}
+	match := re.FindStringSubmatch(text)
+	if match == nil {
+		return nil, nil
+	}
+	if i := re.SubexpIndex("value"); i != -1 {
+		return match[i], nil
+	}
+	if len(match) > 1 {
+		return match[1], nil
+	}
+	return match[0], nil
+}
+
+func decodeCSV(body io.Reader, opts *csvOptions) (interface{}, error) {
+	r := csv.NewReader(body)
+	r.TrimLeadingSpace = true
+	if opts != nil && opts.Delimiter != "" {
+		delimiter, size := utf8.DecodeRuneInString(opts.Delimiter)
+		if size != len(opts.Delimiter) {
+			return nil, errors.Errorf("csv delimiter should be a single character, got '%s'", opts.Delimiter)
+		}
+		r.Comma = delimiter
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]interface{}, 0, len(records))
+	if opts != nil && opts.NoHeader {
+		for _, record := range records {
+			row := make([]interface{}, 0, len(record))
+			for _, v := range record {
+				row = append(row, v)
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+
+	if len(records) == 0 {
+		return rows, nil
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// decodeXML converts an XML document to a map. Attributes are stored with
+// the '@' prefix, the text of an element with attributes or children is stored
+// under '#text', repeated elements become arrays. Namespaces are ignored.
+func decodeXML(body io.Reader) (interface{}, error) {
+	d := xml.NewDecoder(body)
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := t.(xml.StartElement); ok {
+			v, err := decodeXMLElement(d, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{start.Name.Local: v}, nil
+		}
+	}
+}
+
+func decodeXMLElement(d *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	element := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+			continue
+		}
+		element["@"+attr.Name.Local] = attr.Value
+	}
+
+	text := strings.Builder{}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(d, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			switch existing := element[name].(type) {
+			case nil:
+				element[name] = child
+			case []interface{}:
+				element[name] = append(existing, child)
+			default:
+				element[name] = []interface{}{existing, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			value := strings.TrimSpace(text.String())
+			if len(element) == 0 {
+				return value, nil
+			}
+			if value != "" {
+				element["#text"] = value
+			}
+			return element, nil
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/0xPolygonID/refresh-service/providers/cache"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
 )
@@ -11,6 +13,8 @@ import (
 type FactoryFlexibleHTTP struct {
 	configuration map[string]FlexibleHTTP
 	httpcli       *http.Client
+	// clients are dedicated clients of providers with the transport section
+	clients map[string]*http.Client
 }
 
 func NewFactoryFlexibleHTTP(configPath string, httpcli *http.Client) (FactoryFlexibleHTTP, error) {
@@ -19,6 +23,11 @@ func NewFactoryFlexibleHTTP(configPath string, httpcli *http.Client) (FactoryFle
 	if err != nil {
 		return FactoryFlexibleHTTP{}, err
 	}
+	return NewFactoryFlexibleHTTPFromBytes(f, httpcli)
+}
+
+// NewFactoryFlexibleHTTPFromBytes is like NewFactoryFlexibleHTTP, but takes the content of the configuration file.
+func NewFactoryFlexibleHTTPFromBytes(f []byte, httpcli *http.Client) (FactoryFlexibleHTTP, error) {
 	if httpcli == nil {
 		httpcli = http.DefaultClient
 	}
@@ -26,9 +35,52 @@ func NewFactoryFlexibleHTTP(configPath string, httpcli *http.Client) (FactoryFle
 	if err := yaml.Unmarshal(f, &cfgs); err != nil {
 		return FactoryFlexibleHTTP{}, err
 	}
+	// entries with other provider types are served by other factories
+	for credentialType, cfg := range cfgs {
+		if !cfg.Provider.isSupported() {
+			delete(cfgs, credentialType)
+		}
+	}
+	for credentialType, cfg := range cfgs {
+		if cfg.Auth != nil {
+			if err := cfg.Auth.validate(); err != nil {
+				return FactoryFlexibleHTTP{}, errors.Errorf("invalid auth for '%s': %v", credentialType, err)
+			}
+		}
+		if cfg.Signing != nil {
+			if err := cfg.Signing.validate(); err != nil {
+				return FactoryFlexibleHTTP{}, errors.Errorf("invalid signing for '%s': %v", credentialType, err)
+			}
+		}
+	}
+	for credentialType, cfg := range cfgs {
+		if cfg.Cache == nil {
+			continue
+		}
+		if err := cfg.Cache.validate(); err != nil {
+			return FactoryFlexibleHTTP{}, errors.Errorf("invalid cache for '%s': %v", credentialType, err)
+		}
+		maxEntries := cfg.Cache.MaxEntries
+		if maxEntries == 0 {
+			maxEntries = defaultCacheMaxEntries
+		}
+		cfg.Cache.store = cache.NewMemory(maxEntries)
+	}
+	clients := make(map[string]*http.Client)
+	for credentialType, cfg := range cfgs {
+		if cfg.Transport == nil {
+			continue
+		}
+		cli, err := cfg.Transport.client(httpcli)
+		if err != nil {
+			return FactoryFlexibleHTTP{}, errors.Errorf("invalid transport for '%s': %v", credentialType, err)
+		}
+		clients[credentialType] = cli
+	}
 	return FactoryFlexibleHTTP{
 		configuration: cfgs,
 		httpcli:       httpcli,
+		clients:       clients,
 	}, nil
 }
 
@@ -38,5 +90,36 @@ func (factory *FactoryFlexibleHTTP) ProduceFlexibleHTTP(credentialType string) (
 		return FlexibleHTTP{}, errors.Errorf("not found configuration for '%s'", credentialType)
 	}
 	fh.httpcli = factory.httpcli
+	if cli, ok := factory.clients[credentialType]; ok {
+		fh.httpcli = cli
+	}
 	return fh, nil
 }
+
+// SetCache replaces the in-memory cache of the provider with a custom implementation.
+// The provider should have the cache section in the configuration.
+func (factory *FactoryFlexibleHTTP) SetCache(credentialType string, c cache.Cache) error {
+	fh, ok := factory.configuration[credentialType]
+	if !ok {
+		return errors.Errorf("not found configuration for '%s'", credentialType)
+	}
+	if fh.Cache == nil {
+		return errors.Errorf("cache is not configured for '%s'", credentialType)
+	}
+	fh.Cache.store = c
+	return nil
+}
+
+// Register adds all configured providers to the registry.
+func (factory *FactoryFlexibleHTTP) Register(registry *providers.Registry) error {
+	for credentialType := range factory.configuration {
+		fh, err := factory.ProduceFlexibleHTTP(credentialType)
+		if err != nil {
+			return err
+		}
+		if err := registry.Register(credentialType, &fh, fh.Settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package flexiblehttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProviderTypeGraphQL is the value of provider.type for GraphQL data providers.
+const ProviderTypeGraphQL = "graphql"
+
+type graphqlRequest struct {
+	Query         string                 `yaml:"query"`
+	OperationName string                 `yaml:"operationName"`
+	Variables     map[string]interface{} `yaml:"variables"`
+}
+
+func (gr *graphqlRequest) build(data TemplateData) ([]byte, error) {
+	if gr == nil || gr.Query == "" {
+		return nil, errors.New("graphql query is not defined")
+	}
+	variables, err := fillJSONBody(gr.Variables, data)
+	if err != nil {
+		return nil, err
+	}
+	payload := struct {
+		Query         string      `json:"query"`
+		OperationName string      `json:"operationName,omitempty"`
+		Variables     interface{} `json:"variables,omitempty"`
+	}{
+		Query:         gr.Query,
+		OperationName: gr.OperationName,
+		Variables:     variables,
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Errorf("failed to marshal graphql request: %v", err)
+	}
+	return b, nil
+}
+
+// graphqlErrors returns an error if the GraphQL response contains errors.
+func graphqlErrors(response interface{}) error {
+	r, ok := response.(map[string]interface{})
+	if !ok {
+		return errors.Errorf("graphql response should be an object, got '%T'", response)
+	}
+	list, ok := r["errors"].([]interface{})
+	if !ok || len(list) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(list))
+	for _, e := range list {
+		if m, ok := e.(map[string]interface{}); ok && m["message"] != nil {
+			messages = append(messages, fmt.Sprintf("%v", m["message"]))
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("%v", e))
+	}
+	return errors.Errorf("graphql errors: %s", strings.Join(messages, "; "))
+}
+
+func (p provider) method() string {
+	if p.Method == "" && p.Type == ProviderTypeGraphQL {
+		return http.MethodPost
+	}
+	return p.Method
+}
@@ -0,0 +1,81 @@
+package flexiblehttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphQLProvide(t *testing.T) {
+	tests := []struct {
+		name          string
+		response      string
+		expected      map[string]interface{}
+		expectedError error
+	}{
+		{
+			name: "Data response",
+			response: `{"data": {"account": {"tokens": [
+				{"symbol": "ETH", "balance": "1"},
+				{"symbol": "MATIC", "balance": "1200145884000"}
+			]}}}`,
+			expected: map[string]interface{}{
+				"balance": "1200145884000",
+			},
+		},
+		{
+			name:          "Errors response",
+			response:      `{"data": null, "errors": [{"message": "account not found"}]}`,
+			expectedError: ErrDataProviderIssue,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodPost, r.Method)
+				require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+				var request struct {
+					Query         string                 `json:"query"`
+					OperationName string                 `json:"operationName"`
+					Variables     map[string]interface{} `json:"variables"`
+				}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+				require.Contains(t, request.Query, "query Balance")
+				require.Equal(t, "Balance", request.OperationName)
+				require.Equal(t, map[string]interface{}{
+					"address": "0x6ae7e07c8763c284b7c91371f934e46c766d0ec6",
+					"chainId": float64(80002),
+				}, request.Variables)
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			factory, err := NewFactoryFlexibleHTTP("./testvectors/graphql.yaml", srv.Client())
+			require.NoError(t, err)
+			provider, err := factory.ProduceFlexibleHTTP("urn:test:GraphQL")
+			require.NoError(t, err)
+			provider.Provider.URL = srv.URL
+
+			fields, err := provider.Provide(context.Background(), &verifiable.W3CCredential{
+				CredentialSubject: map[string]interface{}{
+					"address": "0x6ae7E07c8763C284B7C91371f934E46c766D0ec6",
+					"chainId": float64(80002),
+				},
+			})
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+				require.Contains(t, err.Error(), "account not found")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, fields)
+		})
+	}
+}
@@ -1,16 +1,22 @@
 package flexiblehttp
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/big"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -19,48 +25,97 @@ var (
 	ErrDataProviderIssue     = errors.New("data provider issue")
 )
 
-type settings struct {
-	TimeExpiration time.Duration `yaml:"timeExpiration"`
-}
+// ProviderType is the value of provider.type for HTTP data providers.
+// HTTP is the default provider type.
+const ProviderType = "http"
 
 type provider struct {
+	Type   string `yaml:"type"`
 	URL    string `yaml:"url"`
 	Method string `yaml:"method"`
 }
 
+// isSupported reports whether the provider type is served by this package.
+func (p provider) isSupported() bool {
+	return p.Type == "" || p.Type == ProviderType || p.Type == ProviderTypeGraphQL
+}
+
 type requestSchema struct {
 	Params  map[string]string `yaml:"params"`
 	Headers map[string]string `yaml:"headers"`
+	Body    *requestBody      `yaml:"body"`
+	GraphQL *graphqlRequest   `yaml:"graphql"`
 }
 
-type responseSchema struct {
+// ResponseSchema describes how to map a provider response to credential fields.
+type ResponseSchema struct {
 	Type       string                  `yaml:"type"`
+	CSV        *csvOptions             `yaml:"csv"`
 	Properties map[string]matchedField `yaml:"properties"`
 }
 
 type matchedField struct {
 	Type    string `yaml:"type"`
 	MatchTo string `yaml:"match"`
+	// Default is used when the field is not found in the response.
+	Default interface{} `yaml:"default"`
+	// Transform is applied to the value before it is converted to Type.
+	Transform []transformStep `yaml:"transform"`
 }
 
 type FlexibleHTTP struct {
 	httpcli        *http.Client
-	Settings       settings       `yaml:"settings"`
-	Provider       provider       `yaml:"provider"`
-	RequestSchema  requestSchema  `yaml:"requestSchema"`
-	ResponseSchema responseSchema `yaml:"responseSchema"`
+	Settings       providers.Settings    `yaml:"settings"`
+	Provider       provider              `yaml:"provider"`
+	RequestSchema  requestSchema         `yaml:"requestSchema"`
+	ResponseSchema ResponseSchema        `yaml:"responseSchema"`
+	Auth           *authConfig           `yaml:"auth"`
+	Signing        *SigningConfig        `yaml:"signing"`
+	Transport      *transportConfig      `yaml:"transport"`
+	Retry          *retryConfig          `yaml:"retry"`
+	Cache          *cacheConfig          `yaml:"cache"`
+	CircuitBreaker *circuitBreakerConfig `yaml:"circuitBreaker"`
+}
+
+// String describes the provider by its type, method and URL template.
+func (fh *FlexibleHTTP) String() string {
+	providerType := fh.Provider.Type
+	if providerType == "" {
+		providerType = ProviderType
+	}
+	method := strings.ToUpper(fh.Provider.method())
+	if method == "" {
+		method = http.MethodGet
+	}
+	return fmt.Sprintf("%s %s %s", providerType, method, fh.Provider.URL)
 }
 
-func (fh *FlexibleHTTP) Provide(credentialSubject map[string]interface{}) (map[string]interface{}, error) {
-	req, err := fh.BuildRequest(credentialSubject)
+// Provide implements providers.Provider.
+func (fh *FlexibleHTTP) Provide(ctx context.Context, credential *verifiable.W3CCredential) (map[string]interface{}, error) {
+	did, _ := credential.CredentialSubject["id"].(string)
+	data, err := NewTemplateData(credential, did)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidRequestSchema, err.Error())
+	}
+	req, body, err := fh.renderRequest(ctx, data)
 	if err != nil {
 		return nil, errors.Wrap(ErrInvalidRequestSchema, err.Error())
 	}
 
-	resp, err := fh.httpcli.Do(req)
+	cacheKey := ""
+	if fh.Cache != nil {
+		cacheKey, err = fh.Cache.key(req, body, data)
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidRequestSchema, err.Error())
+		}
+		if fields, ok := fh.Cache.get(cacheKey); ok {
+			return fields, nil
+		}
+	}
+
+	resp, err := fh.do(ctx, req, body)
 	if err != nil {
-		return nil, errors.Wrapf(ErrDataProviderIssue,
-			"failed http request: %v", err)
+		return nil, err
 	}
 	defer func() {
 		_ = resp.Body.Close()
@@ -70,153 +125,227 @@ func (fh *FlexibleHTTP) Provide(credentialSubject map[string]interface{}) (map[s
 		return nil, errors.Wrapf(ErrDataProviderIssue,
 			"unexpected status code '%d'", resp.StatusCode)
 	}
-	response := map[string]interface{}{}
-	if err := yaml.NewDecoder(resp.Body).Decode(&response); err != nil {
+	response, err := fh.ResponseSchema.decode(resp.Body)
+	if err != nil {
 		return nil, errors.Wrapf(ErrDataProviderIssue, "failed to decode response: %v", err)
 	}
+	if fh.Provider.Type == ProviderTypeGraphQL {
+		if err := graphqlErrors(response); err != nil {
+			return nil, errors.Wrap(ErrDataProviderIssue, err.Error())
+		}
+	}
 
 	decodedResponse, err := fh.DecodeResponse(response)
 	if err != nil {
 		return nil, errors.Wrapf(ErrInvalidResponseSchema,
 			"failed to decode response by response schema: %v", err)
 	}
+	if fh.Cache != nil {
+		fh.Cache.set(cacheKey, decodedResponse)
+	}
 	return decodedResponse, nil
 }
 
-func (fh *FlexibleHTTP) BuildRequest(credentialSubject map[string]interface{}) (*http.Request, error) {
-	u, err := url.Parse(fh.Provider.URL)
+// BuildRequest builds the request to the data provider: renders the templates,
+// adds credentials from the auth section and signs the request.
+func (fh *FlexibleHTTP) BuildRequest(ctx context.Context, data TemplateData) (*http.Request, error) {
+	request, body, err := fh.renderRequest(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := fh.authorizeAndSign(request, body); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Redacted replaces credentials in requests built by BuildRedactedRequest.
+const Redacted = "<redacted>"
+
+// BuildRedactedRequest builds the request like BuildRequest, but the headers
+// and query parameters set by the auth section and the signer are replaced
+// with Redacted. The request is for printing only.
+func (fh *FlexibleHTTP) BuildRedactedRequest(ctx context.Context, data TemplateData) (*http.Request, error) {
+	request, body, err := fh.renderRequest(ctx, data)
 	if err != nil {
 		return nil, err
 	}
+	headers := request.Header.Clone()
+	query := request.URL.Query()
+	if err := fh.authorizeAndSign(request, body); err != nil {
+		return nil, err
+	}
 
-	urlParts := strings.Split(u.Path, "/")
-	for i, part := range urlParts {
-		if isPlaceholder(part) {
-			value, err := findPlaceholderValue(part, credentialSubject)
-			if err != nil {
-				return nil, err
-			}
-			urlParts[i] = fmt.Sprintf("%v", value)
+	for name, values := range request.Header {
+		if !slices.Equal(values, headers[name]) {
+			request.Header[name] = []string{Redacted}
+		}
+	}
+	signedQuery := request.URL.Query()
+	for name, values := range signedQuery {
+		if !slices.Equal(values, query[name]) {
+			signedQuery[name] = []string{Redacted}
 		}
 	}
-	u.Path = strings.Join(urlParts, "/")
+	request.URL.RawQuery = signedQuery.Encode()
+	return request, nil
+}
+
+// renderRequest builds the request from the templates, without auth and signature.
+// The request body is returned to be signed.
+func (fh *FlexibleHTTP) renderRequest(ctx context.Context, data TemplateData) (*http.Request, []byte, error) {
+	u, err := url.Parse(fh.Provider.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u.Host, err = data.Render(u.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+	u.Path, err = data.Render(u.Path)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	q := u.Query()
-	for argK, argV := range fh.RequestSchema.Params {
-		if isPlaceholder(argV) {
-			value, err := findPlaceholderValue(argV, credentialSubject)
+	for argK, argVs := range q {
+		for i, argV := range argVs {
+			argVs[i], err = data.Render(argV)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
-			argV = fmt.Sprintf("%v", value)
+		}
+		q[argK] = argVs
+	}
+	for argK, argV := range fh.RequestSchema.Params {
+		argV, err = data.Render(argV)
+		if err != nil {
+			return nil, nil, err
 		}
 		q.Add(argK, argV)
 	}
 	u.RawQuery = q.Encode()
 
-	request, err := http.NewRequest(
-		fh.Provider.Method,
+	var body []byte
+	if fh.Provider.Type == ProviderTypeGraphQL {
+		body, err = fh.RequestSchema.GraphQL.build(data)
+	} else {
+		body, err = fh.RequestSchema.Body.build(data)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var bodyReader io.Reader = http.NoBody
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(
+		ctx,
+		fh.Provider.method(),
 		u.String(),
-		http.NoBody,
+		bodyReader,
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	for headerK, headerV := range fh.RequestSchema.Headers {
+		headerV, err = data.Render(headerV)
+		if err != nil {
+			return nil, nil, err
+		}
 		request.Header.Add(headerK, headerV)
 	}
+	if request.Header.Get("Content-Type") == "" {
+		switch {
+		case fh.Provider.Type == ProviderTypeGraphQL:
+			request.Header.Set("Content-Type", "application/json")
+		case !fh.RequestSchema.Body.isEmpty():
+			request.Header.Set("Content-Type", fh.RequestSchema.Body.contentType())
+		}
+	}
 
-	return request, nil
+	return request, body, nil
 }
 
-func (fh *FlexibleHTTP) DecodeResponse(response map[string]interface{}) (map[string]interface{}, error) {
-	parsedFields := make(map[string]interface{})
-	for propertyKey, propertyValue := range fh.ResponseSchema.Properties {
-		parts := strings.Split(propertyKey, ".")
-		for i, part := range parts {
-			tragetKey, targetIndex := processKey(part)
-			if tragetKey == "" {
-				return nil, errors.Errorf("invalid key '%s'", part)
-			}
-
-			v, ok := response[tragetKey]
-			if !ok {
-				return nil, errors.Errorf("not found field '%s' in response", parts[:i+1])
-			}
-			switch v := v.(type) {
-			case map[string]interface{}:
-				response = v
-			case []interface{}:
-				if targetIndex == -1 {
-					return nil, errors.Errorf("not found index for '%s'", part)
-				}
-				if targetIndex >= len(v) {
-					return nil, errors.Errorf("index out of range for '%s'", part)
-				}
-				tmp := v[targetIndex]
-				switch tmp := tmp.(type) {
-				case map[string]interface{}:
-					response = tmp
-				default:
-					p := strings.Split(propertyValue.MatchTo, ".")
-					if len(p) != 2 {
-						return nil, errors.Errorf("invalid match field for '%s'", propertyKey)
-					}
-					var err error
-					parsedFields[p[1]], err = castToType(v[targetIndex], propertyValue.Type)
-					if err != nil {
-						return nil, err
-					}
-				}
-			default:
-				p := strings.Split(propertyValue.MatchTo, ".")
-				if len(p) != 2 {
-					return nil, errors.Errorf("invalid match field for '%s'", propertyKey)
-				}
-				var err error
-				parsedFields[p[1]], err = castToType(v, propertyValue.Type)
-				if err != nil {
-					return nil, err
-				}
-			}
+// authorizeAndSign adds credentials from the auth section and signs the request.
+func (fh *FlexibleHTTP) authorizeAndSign(request *http.Request, body []byte) error {
+	if err := fh.Authorize(request); err != nil {
+		return err
+	}
+	if fh.Signing != nil {
+		signer, err := fh.Signing.signer()
+		if err != nil {
+			return err
+		}
+		if err := signer.Sign(request, body); err != nil {
+			return errors.Errorf("failed to sign request: %v", err)
 		}
 	}
-
-	return parsedFields, nil
+	return nil
 }
 
-func isPlaceholder(v string) bool {
-	return strings.HasPrefix(v, "{{") && strings.HasSuffix(v, "}}")
+// DecodeResponse extracts credential fields from the decoded provider response.
+func (fh *FlexibleHTTP) DecodeResponse(response interface{}) (map[string]interface{}, error) {
+	return fh.ResponseSchema.Map(response)
 }
 
-// nolint:gocritic // clear with named return
-func processKey(key string) (string, int) {
-	startIdx := strings.Index(key, "[")
-	endIdx := strings.Index(key, "]")
-	if startIdx == -1 || endIdx == -1 {
-		return key, -1
-	}
-	arrayIdx, err := strconv.Atoi(key[startIdx+1 : endIdx])
-	if err != nil {
-		return key, -1
-	}
-	return key[:startIdx], arrayIdx
-}
+// Map extracts credential fields from the decoded response.
+// Keys of properties are JMESPath expressions, or regular
+// expressions for the text response type.
+func (rs *ResponseSchema) Map(response interface{}) (map[string]interface{}, error) {
+	parsedFields := make(map[string]interface{})
+	for propertyKey, propertyValue := range rs.Properties {
+		v, err := rs.search(propertyKey, response)
+		if err != nil {
+			return nil, err
+		}
+		// a filter or a wildcard projection that matches exactly one element
+		// is treated as a single value, unless the field is an array
+		if list, ok := v.([]interface{}); ok && propertyValue.Type != "array" {
+			switch len(list) {
+			case 0:
+				v = nil
+			case 1:
+				v = list[0]
+			}
+		}
+		if v == nil {
+			v = propertyValue.Default
+		}
+		if v == nil {
+			return nil, errors.Errorf("not found field '%s' in response", propertyKey)
+		}
+		v, err = transform(v, propertyValue.Transform)
+		if err != nil {
+			return nil, errors.Errorf("field '%s': %v", propertyKey, err)
+		}
 
-func findPlaceholderValue(placeholder string, values map[string]interface{}) (interface{}, error) {
-	placeHolder := strings.Trim(placeholder, "{ }")
-	pair := strings.Split(placeHolder, ".")
-	if len(pair) != 2 {
-		return nil, errors.Errorf("invalid placeholder fromat: '%s'", placeHolder)
-	}
-	v, ok := values[pair[1]]
-	if !ok {
-		return nil, errors.Errorf("not found value for placeholder: '%s'", placeHolder)
+		p := strings.Split(propertyValue.MatchTo, ".")
+		if len(p) < 2 {
+			return nil, errors.Errorf("invalid match field for '%s'", propertyKey)
+		}
+		v, err = castToType(v, propertyValue.Type)
+		if err != nil {
+			return nil, errors.Errorf("field '%s': %v", propertyKey, err)
+		}
+		if err := setField(parsedFields, p[1:], v); err != nil {
+			return nil, errors.Errorf("invalid match field for '%s': %v", propertyKey, err)
+		}
 	}
-	return v, nil
+
+	return parsedFields, nil
 }
 
 func castToType(v interface{}, toType string) (interface{}, error) {
+	switch toType {
+	case "bigint", "decimal-string", "positive-integer", "date", "datetime":
+		return castToExtendedType(v, toType)
+	case "object", "array":
+		return castToComposite(v, toType)
+	}
+
 	switch valueType := v.(type) {
 	case string:
 		return stringToType(valueType, toType)
@@ -224,6 +353,14 @@ func castToType(v interface{}, toType string) (interface{}, error) {
 		return float64ToType(valueType, toType)
 	case bool:
 		return booleanToType(valueType, toType)
+	case int:
+		return intToType(int64(valueType), toType)
+	case int64:
+		return intToType(valueType, toType)
+	case time.Time:
+		return stringToType(valueType.Format(time.RFC3339), toType)
+	case json.Number:
+		return stringToType(string(valueType), toType)
 	default:
 		return nil, errors.Errorf("invalid type '%T' from JSON response", v)
 	}
@@ -258,6 +395,19 @@ func float64ToType(value float64, convertType string) (interface{}, error) {
 	}
 }
 
+func intToType(value int64, convertType string) (interface{}, error) {
+	switch convertType {
+	case "string":
+		return strconv.FormatInt(value, 10), nil
+	case "integer":
+		return int(value), nil
+	case "double", "number", "float":
+		return float64(value), nil
+	default:
+		return nil, errors.Errorf("not possible convert integer to '%s'", convertType)
+	}
+}
+
 func doubleToInt(v float64) (int, error) {
 	r := new(big.Rat).SetFloat64(v)
 	if r.Denom().Cmp(big.NewInt(1)) != 0 {
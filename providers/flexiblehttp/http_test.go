@@ -2,11 +2,16 @@ package flexiblehttp
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
+	"github.com/iden3/go-schema-processor/v2/verifiable"
 	"github.com/stretchr/testify/require"
 )
 
@@ -34,15 +39,27 @@ func TestNewBuilder(t *testing.T) {
 				"Content-Type": {"application/json"},
 			},
 		},
+		{
+			name:             "Template in the query of the URL",
+			credentialType:   "urn:test:URLQuery",
+			pathToTestVector: "./testvectors/balance.yaml",
+			credentialSubject: map[string]interface{}{
+				"address": "0x6ae7E07c8763C284B7C91371f934E46c766D0ec6",
+			},
+			expectedURL:     "https://api.example.com/balance?chain=polygon&addr=0x6ae7E07c8763C284B7C91371f934E46c766D0ec6&module=account",
+			expectedMethod:  "GET",
+			expectedHeaders: map[string][]string{},
+		},
 	}
 
+	t.Setenv("PROVIDER_POLYGONSCAN_API_KEY", "RET2WHC1B3UDM9PQQ12ZUG2ZE289D1TCY9")
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			factory, err := NewFactoryFlexibleHTTP(tt.pathToTestVector, nil)
 			require.NoError(t, err)
 			provider, err := factory.ProduceFlexibleHTTP(tt.credentialType)
 			require.NoError(t, err)
-			request, err := provider.BuildRequest(tt.credentialSubject)
+			request, err := provider.BuildRequest(context.Background(), TemplateData{CredentialSubject: tt.credentialSubject})
 			require.NoError(t, err)
 
 			compareURLs(t, tt.expectedURL, request.URL.String())
@@ -229,6 +246,67 @@ func TestCastToType(t *testing.T) {
 			convertType: "boolean",
 			expected:    true,
 		},
+		// extended types
+		{
+			name:        "large string to bigint",
+			jsonBody:    `{"data": "123456789012345678901234567890"}`,
+			convertType: "bigint",
+			expected:    "123456789012345678901234567890",
+		},
+		{
+			name:        "float to bigint",
+			jsonBody:    `{"data": 1e21}`,
+			convertType: "bigint",
+			expected:    "1000000000000000000000",
+		},
+		{
+			name:        "string to decimal-string",
+			jsonBody:    `{"data": "0001234.5000"}`,
+			convertType: "decimal-string",
+			expected:    "1234.5",
+		},
+		{
+			name:        "string to positive-integer",
+			jsonBody:    `{"data": "42"}`,
+			convertType: "positive-integer",
+			expected:    "42",
+		},
+		{
+			name:        "large string to positive-integer",
+			jsonBody:    `{"data": "123456789012345678901234567890"}`,
+			convertType: "positive-integer",
+			expected:    "123456789012345678901234567890",
+		},
+		{
+			name:        "datetime to date",
+			jsonBody:    `{"data": "2024-01-31T23:00:00+02:00"}`,
+			convertType: "date",
+			expected:    "2024-01-31",
+		},
+		{
+			name:        "unix timestamp to datetime",
+			jsonBody:    `{"data": 1706695200}`,
+			convertType: "datetime",
+			expected:    "2024-01-31T10:00:00Z",
+		},
+		{
+			name:        "date to datetime",
+			jsonBody:    `{"data": "2024-01-31"}`,
+			convertType: "datetime",
+			expected:    "2024-01-31T00:00:00Z",
+		},
+		{
+			name:        "object to object",
+			jsonBody:    `{"data": {"city": "Kyiv"}}`,
+			convertType: "object",
+			expected:    map[string]interface{}{"city": "Kyiv"},
+		},
+		{
+			name:        "array to array",
+			jsonBody:    `{"data": ["a", 1]}`,
+			convertType: "array",
+			expected:    []interface{}{"a", float64(1)},
+		},
 	}
 
 	for _, tt := range tests {
@@ -259,6 +337,31 @@ func TestCastToType_Error(t *testing.T) {
 			jsonBody:    `{"data": 123456789012345678901234567890.12345}`,
 			convertType: "integer",
 		},
+		{
+			name:        "fraction to bigint",
+			jsonBody:    `{"data": "1.5"}`,
+			convertType: "bigint",
+		},
+		{
+			name:        "zero to positive-integer",
+			jsonBody:    `{"data": 0}`,
+			convertType: "positive-integer",
+		},
+		{
+			name:        "invalid date",
+			jsonBody:    `{"data": "31/01/2024"}`,
+			convertType: "date",
+		},
+		{
+			name:        "string to object",
+			jsonBody:    `{"data": "Kyiv"}`,
+			convertType: "object",
+		},
+		{
+			name:        "object to array",
+			jsonBody:    `{"data": {"city": "Kyiv"}}`,
+			convertType: "array",
+		},
 	}
 
 	for _, tt := range tests {
@@ -271,3 +374,376 @@ func TestCastToType_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildRequestBody(t *testing.T) {
+	credentialSubject := map[string]interface{}{
+		"address":  "0x6ae7E07c8763C284B7C91371f934E46c766D0ec6",
+		"currency": "MATIC",
+		"age":      float64(21),
+	}
+	tests := []struct {
+		name                string
+		credentialType      string
+		expectedMethod      string
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "JSON body with nested objects and arrays",
+			credentialType:      "urn:test:JSONBody",
+			expectedMethod:      http.MethodPost,
+			expectedContentType: "application/json",
+			expectedBody: `{
+				"subject": {"address": "0x6ae7E07c8763C284B7C91371f934E46c766D0ec6", "age": 21},
+				"tags": ["refresh", "MATIC"],
+				"note": "balance in MATIC"
+			}`,
+		},
+		{
+			name:                "Form body",
+			credentialType:      "urn:test:FormBody",
+			expectedMethod:      http.MethodPost,
+			expectedContentType: "application/x-www-form-urlencoded",
+			expectedBody:        "account=0x6ae7E07c8763C284B7C91371f934E46c766D0ec6&currency=MATIC&scope=balance",
+		},
+		{
+			name:                "Text body keeps configured content type",
+			credentialType:      "urn:test:TextBody",
+			expectedMethod:      http.MethodPut,
+			expectedContentType: "application/xml",
+			expectedBody:        "<query><account>0x6ae7E07c8763C284B7C91371f934E46c766D0ec6</account></query>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factory, err := NewFactoryFlexibleHTTP("./testvectors/body.yaml", nil)
+			require.NoError(t, err)
+			provider, err := factory.ProduceFlexibleHTTP(tt.credentialType)
+			require.NoError(t, err)
+			request, err := provider.BuildRequest(context.Background(), TemplateData{CredentialSubject: credentialSubject})
+			require.NoError(t, err)
+
+			require.Equal(t, tt.expectedMethod, request.Method)
+			require.Equal(t, tt.expectedContentType, request.Header.Get("Content-Type"))
+			body, err := io.ReadAll(request.Body)
+			require.NoError(t, err)
+			if tt.expectedContentType == "application/json" {
+				require.JSONEq(t, tt.expectedBody, string(body))
+			} else {
+				require.Equal(t, tt.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+func TestBuildRequestBody_Error(t *testing.T) {
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/body.yaml", nil)
+	require.NoError(t, err)
+	provider, err := factory.ProduceFlexibleHTTP("urn:test:JSONBody")
+	require.NoError(t, err)
+	_, err = provider.BuildRequest(context.Background(), TemplateData{
+		CredentialSubject: map[string]interface{}{
+			"address": "0x6ae7E07c8763C284B7C91371f934E46c766D0ec6",
+		},
+	})
+	require.Error(t, err)
+}
+
+func TestDecodeResponse_Query(t *testing.T) {
+	responseBody := []byte(`{
+		"items": [
+			{"currency": "ETH", "balance": "1", "amount": 10},
+			{"currency": "MATIC", "balance": "1200145884000", "amount": 32}
+		],
+		"accounts": {
+			"main": {"owner": "0x6ae7E07c8763C284B7C91371f934E46c766D0ec6"}
+		},
+		"history": [
+			{"balance": "100"},
+			{"balance": "200"}
+		]
+	}`)
+	tests := []struct {
+		name                  string
+		credentialType        string
+		expectedUpdatedFields map[string]interface{}
+	}{
+		{
+			name:           "Filter expression",
+			credentialType: "urn:test:Filter",
+			expectedUpdatedFields: map[string]interface{}{
+				"balance": "1200145884000",
+			},
+		},
+		{
+			name:           "Wildcard expression",
+			credentialType: "urn:test:Wildcard",
+			expectedUpdatedFields: map[string]interface{}{
+				"owner": "0x6ae7E07c8763C284B7C91371f934E46c766D0ec6",
+			},
+		},
+		{
+			name:           "Last element index",
+			credentialType: "urn:test:LastElement",
+			expectedUpdatedFields: map[string]interface{}{
+				"balance": "200",
+			},
+		},
+		{
+			name:           "Aggregate functions",
+			credentialType: "urn:test:Aggregate",
+			expectedUpdatedFields: map[string]interface{}{
+				"total":    42,
+				"count":    2,
+				"currency": "MATIC",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factory, err := NewFactoryFlexibleHTTP("./testvectors/query.yaml", nil)
+			require.NoError(t, err)
+			provider, err := factory.ProduceFlexibleHTTP(tt.credentialType)
+			require.NoError(t, err)
+			var response interface{}
+			require.NoError(t, json.Unmarshal(responseBody, &response))
+			updatedFields, err := provider.DecodeResponse(response)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedUpdatedFields, updatedFields)
+		})
+	}
+}
+
+func TestDecodeResponse_Error(t *testing.T) {
+	tests := []struct {
+		name           string
+		credentialType string
+		responseBody   []byte
+	}{
+		{
+			name:           "Not a map in the middle of the path",
+			credentialType: "urn:test:NotMapInPath",
+			responseBody:   []byte(`{"result": "1200145884000"}`),
+		},
+		{
+			name:           "Filter without matches",
+			credentialType: "urn:test:Filter",
+			responseBody:   []byte(`{"items": [{"currency": "ETH", "balance": "1"}]}`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factory, err := NewFactoryFlexibleHTTP("./testvectors/query.yaml", nil)
+			require.NoError(t, err)
+			provider, err := factory.ProduceFlexibleHTTP(tt.credentialType)
+			require.NoError(t, err)
+			var response interface{}
+			require.NoError(t, json.Unmarshal(tt.responseBody, &response))
+			_, err = provider.DecodeResponse(response)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestDecodeResponse_Types(t *testing.T) {
+	tests := []struct {
+		name                  string
+		credentialType        string
+		responseBody          string
+		expectedUpdatedFields map[string]interface{}
+	}{
+		{
+			name:           "XML response",
+			credentialType: "urn:test:XML",
+			responseBody: `<?xml version="1.0" encoding="UTF-8"?>
+				<account xmlns="urn:bank" currency="MATIC">
+					<balance>1200145884000</balance>
+					<history>
+						<entry>1</entry>
+						<entry>2</entry>
+					</history>
+				</account>`,
+			expectedUpdatedFields: map[string]interface{}{
+				"balance":   "1200145884000",
+				"currency":  "MATIC",
+				"lastEntry": 2,
+			},
+		},
+		{
+			name:           "CSV response with header",
+			credentialType: "urn:test:CSV",
+			responseBody:   "currency;balance\nETH;1\nMATIC;1200145884000\n",
+			expectedUpdatedFields: map[string]interface{}{
+				"balance": "1200145884000",
+			},
+		},
+		{
+			name:           "CSV response without header",
+			credentialType: "urn:test:CSVNoHeader",
+			responseBody:   "ETH,0x1,1\nMATIC,0x2,100\n",
+			expectedUpdatedFields: map[string]interface{}{
+				"balance": 100,
+			},
+		},
+		{
+			name:           "Text response",
+			credentialType: "urn:test:Text",
+			responseBody:   "status=active\nbalance: 1200\n",
+			expectedUpdatedFields: map[string]interface{}{
+				"balance": 1200,
+				"status":  "active",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factory, err := NewFactoryFlexibleHTTP("./testvectors/decoders.yaml", nil)
+			require.NoError(t, err)
+			provider, err := factory.ProduceFlexibleHTTP(tt.credentialType)
+			require.NoError(t, err)
+			response, err := provider.ResponseSchema.decode(strings.NewReader(tt.responseBody))
+			require.NoError(t, err)
+			updatedFields, err := provider.DecodeResponse(response)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedUpdatedFields, updatedFields)
+		})
+	}
+}
+
+func TestProvide(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "0x6ae7E07c8763C284B7C91371f934E46c766D0ec6", r.URL.Query().Get("address"))
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write([]byte(`<account currency="MATIC"><balance>42</balance></account>`))
+	}))
+	defer srv.Close()
+
+	fh := FlexibleHTTP{
+		httpcli: srv.Client(),
+		Provider: provider{
+			URL:    srv.URL,
+			Method: http.MethodGet,
+		},
+		RequestSchema: requestSchema{
+			Params: map[string]string{
+				"address": "{{ credentialSubject.address }}",
+			},
+		},
+		ResponseSchema: ResponseSchema{
+			Type: responseTypeXML,
+			Properties: map[string]matchedField{
+				"account.balance": {
+					Type:    "integer",
+					MatchTo: "credentialSubject.balance",
+				},
+			},
+		},
+	}
+	updatedFields, err := fh.Provide(context.Background(), &verifiable.W3CCredential{
+		CredentialSubject: map[string]interface{}{
+			"address": "0x6ae7E07c8763C284B7C91371f934E46c766D0ec6",
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"balance": 42}, updatedFields)
+}
+
+func TestDecodeResponse_ExtendedTypes(t *testing.T) {
+	responseBody := `{
+		"balance": 123456789012345678901234567890,
+		"count": 3,
+		"address": {"city": "Kyiv", "zip": 1001},
+		"tags": ["kyc", "aml"],
+		"owner": {"name": "Alice", "age": 30}
+	}`
+	tests := []struct {
+		name                  string
+		credentialType        string
+		expectedUpdatedFields map[string]interface{}
+	}{
+		{
+			name:           "Big integers keep precision",
+			credentialType: "urn:test:BigInteger",
+			expectedUpdatedFields: map[string]interface{}{
+				"balance":        "123456789012345678901234567890",
+				"balanceInEther": "123456789012.34567890123456789",
+				"count":          "3",
+			},
+		},
+		{
+			name:           "Nested objects and arrays",
+			credentialType: "urn:test:Nested",
+			expectedUpdatedFields: map[string]interface{}{
+				"address": map[string]interface{}{
+					"city": "Kyiv",
+					"zip":  1001,
+				},
+				"tags":  []interface{}{"kyc", "aml"},
+				"owner": map[string]interface{}{"name": "Alice", "age": float64(30)},
+			},
+		},
+	}
+
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/types.yaml", nil)
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := factory.ProduceFlexibleHTTP(tt.credentialType)
+			require.NoError(t, err)
+			response, err := provider.ResponseSchema.decode(strings.NewReader(responseBody))
+			require.NoError(t, err)
+			updatedFields, err := provider.DecodeResponse(response)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedUpdatedFields, updatedFields)
+		})
+	}
+
+	provider, err := factory.ProduceFlexibleHTTP("urn:test:InvalidNested")
+	require.NoError(t, err)
+	response, err := provider.ResponseSchema.decode(strings.NewReader(responseBody))
+	require.NoError(t, err)
+	_, err = provider.DecodeResponse(response)
+	require.ErrorContains(t, err, "'count")
+}
+
+func TestDecodeResponse_Array(t *testing.T) {
+	tests := []struct {
+		name         string
+		responseBody string
+		expectedTags []interface{}
+	}{
+		{
+			name:         "Empty array",
+			responseBody: `{"tags": []}`,
+			expectedTags: []interface{}{},
+		},
+		{
+			name:         "Array with one element",
+			responseBody: `{"tags": ["kyc"]}`,
+			expectedTags: []interface{}{"kyc"},
+		},
+		{
+			name:         "Array with many elements",
+			responseBody: `{"tags": ["kyc", "aml", "pep"]}`,
+			expectedTags: []interface{}{"kyc", "aml", "pep"},
+		},
+	}
+
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/types.yaml", nil)
+	require.NoError(t, err)
+	provider, err := factory.ProduceFlexibleHTTP("urn:test:Array")
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := provider.ResponseSchema.decode(strings.NewReader(tt.responseBody))
+			require.NoError(t, err)
+			updatedFields, err := provider.DecodeResponse(response)
+			require.NoError(t, err)
+			require.Equal(t, map[string]interface{}{"tags": tt.expectedTags}, updatedFields)
+		})
+	}
+}
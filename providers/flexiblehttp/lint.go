@@ -0,0 +1,277 @@
+package flexiblehttp
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"github.com/jmespath/go-jmespath"
+	"github.com/pkg/errors"
+)
+
+// MatchPrefix is the prefix of match targets in the response schema.
+const MatchPrefix = "credentialSubject."
+
+// supportedTypes are the property types of the response schema.
+var supportedTypes = map[string]bool{
+	"string":           true,
+	"integer":          true,
+	"double":           true,
+	"number":           true,
+	"float":            true,
+	"boolean":          true,
+	"bool":             true,
+	"bigint":           true,
+	"decimal-string":   true,
+	"positive-integer": true,
+	"date":             true,
+	"datetime":         true,
+	"object":           true,
+	"array":            true,
+}
+
+// templateRoots are the names of the template data.
+var templateRoots = map[string]bool{
+	"credentialSubject": true,
+	"credential":        true,
+	"did":               true,
+}
+
+var supportedMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+	http.MethodHead:   true,
+}
+
+// LintTemplate checks that the text is a valid template and that placeholders
+// reference the template data: `{{ .credentialSubject.address }}` instead of `{{ .address }}`.
+func LintTemplate(text string) error {
+	if !strings.Contains(text, "{{") {
+		return nil
+	}
+	t, err := TemplateData{}.parse(text)
+	if err != nil {
+		return err
+	}
+	return lintNode(t.Tree.Root, true)
+}
+
+// lintNode walks the template tree. Fields of the dot are checked
+// only at the top level, range and with blocks change the dot.
+func lintNode(node parse.Node, topLevel bool) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Nodes {
+			if err := lintNode(c, topLevel); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return lintNode(n.Pipe, topLevel)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				if err := lintNode(arg, topLevel); err != nil {
+					return err
+				}
+			}
+		}
+	case *parse.IfNode:
+		return lintBranch(&n.BranchNode, topLevel, topLevel)
+	case *parse.RangeNode:
+		return lintBranch(&n.BranchNode, topLevel, false)
+	case *parse.WithNode:
+		return lintBranch(&n.BranchNode, topLevel, false)
+	case *parse.FieldNode:
+		if topLevel && !templateRoots[n.Ident[0]] {
+			return errors.Errorf("placeholder '%s' should start with one of "+
+				"'.credentialSubject', '.credential' or '.did'", n)
+		}
+	}
+	return nil
+}
+
+func lintBranch(n *parse.BranchNode, topLevel, inner bool) error {
+	if err := lintNode(n.Pipe, topLevel); err != nil {
+		return err
+	}
+	if err := lintNode(n.List, inner); err != nil {
+		return err
+	}
+	return lintNode(n.ElseList, topLevel)
+}
+
+// lintValue checks templates in all strings of a body or variables value.
+func lintValue(v interface{}) error {
+	switch value := v.(type) {
+	case string:
+		return LintTemplate(value)
+	case map[string]interface{}:
+		for _, item := range value {
+			if err := lintValue(item); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if err := lintValue(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Lint checks the response schema and returns all found issues.
+func (rs *ResponseSchema) Lint() []error {
+	var issues []error
+	switch rs.Type {
+	case responseTypeJSON, responseTypeXML, responseTypeCSV, responseTypeText, "":
+	default:
+		issues = append(issues, errors.Errorf("unsupported response type '%s'", rs.Type))
+	}
+	if len(rs.Properties) == 0 {
+		issues = append(issues, errors.New("response schema has no properties"))
+	}
+
+	targets := make(map[string]string, len(rs.Properties))
+	for _, key := range sortedKeys(rs.Properties) {
+		property := rs.Properties[key]
+		if rs.Type == responseTypeText {
+			if _, err := regexp.Compile(key); err != nil {
+				issues = append(issues, errors.Errorf("property '%s': invalid regular expression: %v", key, err))
+			}
+		} else if _, err := jmespath.Compile(key); err != nil {
+			issues = append(issues, errors.Errorf("property '%s': invalid JMESPath expression: %v", key, err))
+		}
+		if !supportedTypes[property.Type] {
+			issues = append(issues, errors.Errorf("property '%s': unsupported type '%s'", key, property.Type))
+		}
+
+		target, ok := strings.CutPrefix(property.MatchTo, MatchPrefix)
+		if !ok || target == "" {
+			issues = append(issues, errors.Errorf("property '%s': match '%s' should start with '%s'",
+				key, property.MatchTo, MatchPrefix))
+			continue
+		}
+		if strings.Contains(target, "..") || strings.HasSuffix(target, ".") {
+			issues = append(issues, errors.Errorf("property '%s': invalid match '%s'", key, property.MatchTo))
+			continue
+		}
+		for other, otherKey := range targets {
+			if other == target || strings.HasPrefix(other, target+".") || strings.HasPrefix(target, other+".") {
+				issues = append(issues, errors.Errorf("properties '%s' and '%s' are matched to conflicting fields",
+					otherKey, key))
+			}
+		}
+		targets[target] = key
+	}
+	return issues
+}
+
+// MatchTargets returns the sorted credential subject fields, without the prefix,
+// that the properties are matched to.
+func (rs *ResponseSchema) MatchTargets() []string {
+	targets := make([]string, 0, len(rs.Properties))
+	for _, property := range rs.Properties {
+		if target, ok := strings.CutPrefix(property.MatchTo, MatchPrefix); ok && target != "" {
+			targets = append(targets, target)
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// Lint checks the provider configuration and returns all found issues.
+// Unlike the provider itself, Lint doesn't stop at the first issue.
+func (fh *FlexibleHTTP) Lint() []error {
+	var issues []error
+	if !fh.Provider.isSupported() {
+		issues = append(issues, errors.Errorf("unsupported provider type '%s'", fh.Provider.Type))
+	}
+	if fh.Provider.URL == "" {
+		issues = append(issues, errors.New("provider url is not defined"))
+	}
+	if method := fh.Provider.method(); method != "" && !supportedMethods[strings.ToUpper(method)] {
+		issues = append(issues, errors.Errorf("unsupported provider method '%s'", fh.Provider.Method))
+	}
+
+	lintTemplate := func(name, text string) {
+		if err := LintTemplate(text); err != nil {
+			issues = append(issues, errors.Errorf("%s: %v", name, err))
+		}
+	}
+	lintTemplate("provider url", fh.Provider.URL)
+	for _, k := range sortedKeys(fh.RequestSchema.Params) {
+		lintTemplate(fmt.Sprintf("param '%s'", k), fh.RequestSchema.Params[k])
+	}
+	for _, k := range sortedKeys(fh.RequestSchema.Headers) {
+		lintTemplate(fmt.Sprintf("header '%s'", k), fh.RequestSchema.Headers[k])
+	}
+	if fh.Provider.Type == ProviderTypeGraphQL {
+		if fh.RequestSchema.GraphQL == nil || fh.RequestSchema.GraphQL.Query == "" {
+			issues = append(issues, errors.New("graphql query is not defined"))
+		} else if err := lintValue(fh.RequestSchema.GraphQL.Variables); err != nil {
+			issues = append(issues, errors.Errorf("graphql variables: %v", err))
+		}
+	} else if !fh.RequestSchema.Body.isEmpty() {
+		switch fh.RequestSchema.Body.Type {
+		case bodyTypeJSON, bodyTypeForm, bodyTypeText, "":
+		default:
+			issues = append(issues, errors.Errorf("unsupported body type '%s'", fh.RequestSchema.Body.Type))
+		}
+		if err := lintValue(fh.RequestSchema.Body.Content); err != nil {
+			issues = append(issues, errors.Errorf("body: %v", err))
+		}
+	}
+
+	if fh.Auth != nil {
+		switch fh.Auth.Type {
+		case authTypeOAuth2, authTypeBearer, authTypeBasic, authTypeAPIKey:
+		default:
+			issues = append(issues, errors.Errorf("unsupported auth type '%s'", fh.Auth.Type))
+		}
+		if err := fh.Auth.validate(); err != nil {
+			issues = append(issues, errors.Errorf("invalid auth: %v", err))
+		}
+	}
+	if fh.Signing != nil {
+		signersMu.RLock()
+		_, ok := signers[fh.Signing.Scheme]
+		signersMu.RUnlock()
+		if !ok {
+			issues = append(issues, errors.Errorf("unsupported signing scheme '%s'", fh.Signing.Scheme))
+		}
+		if err := fh.Signing.validate(); err != nil {
+			issues = append(issues, errors.Errorf("invalid signing: %v", err))
+		}
+	}
+	if fh.Cache != nil {
+		if err := fh.Cache.validate(); err != nil {
+			issues = append(issues, errors.Errorf("invalid cache: %v", err))
+		}
+	}
+
+	return append(issues, fh.ResponseSchema.Lint()...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
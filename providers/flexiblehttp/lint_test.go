@@ -0,0 +1,34 @@
+package flexiblehttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintTemplate(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		expectedError string
+	}{
+		{name: "plain text", text: "https://example.com"},
+		{name: "function", text: "{{ credentialSubject.address }}"},
+		{name: "field", text: "{{ .credentialSubject.address | lower }}"},
+		{name: "did", text: "{{ did }}"},
+		{name: "range", text: "{{ range .credentialSubject.items }}{{ .id }}{{ end }}"},
+		{name: "if", text: "{{ if .credential.id }}{{ .id }}{{ end }}", expectedError: "placeholder '.id'"},
+		{name: "no prefix", text: "{{ .address }}", expectedError: "placeholder '.address'"},
+		{name: "unknown function", text: "{{ address }}", expectedError: "function \"address\" not defined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := LintTemplate(tt.text)
+			if tt.expectedError != "" {
+				require.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
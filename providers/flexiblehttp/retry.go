@@ -0,0 +1,280 @@
+package flexiblehttp
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultInitialInterval = 200 * time.Millisecond
+	defaultMaxInterval     = 5 * time.Second
+	defaultMultiplier      = 2
+
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+)
+
+// Circuit breaker states.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// retryConfig is the retry section of the provider configuration.
+// Requests are retried on network errors, 5xx and 429 responses.
+type retryConfig struct {
+	// Attempts is the maximum number of attempts including the first one.
+	Attempts        int           `yaml:"attempts"`
+	InitialInterval time.Duration `yaml:"initialInterval"`
+	MaxInterval     time.Duration `yaml:"maxInterval"`
+	Multiplier      float64       `yaml:"multiplier"`
+}
+
+func (rc *retryConfig) attempts() int {
+	if rc == nil || rc.Attempts < 1 {
+		return 1
+	}
+	return rc.Attempts
+}
+
+// backoff returns the delay before the attempt with exponential growth and jitter.
+func (rc *retryConfig) backoff(attempt int) time.Duration {
+	initial, maxInterval, multiplier := rc.InitialInterval, rc.MaxInterval, rc.Multiplier
+	if initial <= 0 {
+		initial = defaultInitialInterval
+	}
+	if maxInterval <= 0 {
+		maxInterval = defaultMaxInterval
+	}
+	if multiplier < 1 {
+		multiplier = defaultMultiplier
+	}
+	d := float64(initial)
+	for i := 1; i < attempt; i++ {
+		d *= multiplier
+		if d >= float64(maxInterval) {
+			d = float64(maxInterval)
+			break
+		}
+	}
+	// equal jitter: half of the delay is fixed, half is random
+	half := d / 2
+	//nolint:gosec // jitter doesn't need a cryptographically secure random
+	return time.Duration(half + rand.Float64()*half)
+}
+
+func (rc *retryConfig) maxInterval() time.Duration {
+	if rc.MaxInterval <= 0 {
+		return defaultMaxInterval
+	}
+	return rc.MaxInterval
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// retryAfter parses the Retry-After header in seconds or as an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// circuitBreakerConfig is the circuitBreaker section of the provider configuration.
+type circuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed calls that opens the circuit.
+	FailureThreshold int `yaml:"failureThreshold"`
+	// OpenTimeout is how long the circuit stays open before a trial call is allowed.
+	OpenTimeout time.Duration `yaml:"openTimeout"`
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+// allow returns an error if the circuit is open.
+func (cb *circuitBreakerConfig) allow() error {
+	if cb == nil {
+		return nil
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case CircuitOpen:
+		openTimeout := cb.OpenTimeout
+		if openTimeout <= 0 {
+			openTimeout = defaultOpenTimeout
+		}
+		if time.Since(cb.openedAt) < openTimeout {
+			return errors.Wrap(ErrDataProviderIssue, "circuit breaker is open")
+		}
+		cb.state = CircuitHalfOpen
+		return nil
+	case CircuitHalfOpen:
+		// only one trial call is allowed in the half-open state
+		return errors.Wrap(ErrDataProviderIssue, "circuit breaker is half-open")
+	default:
+		return nil
+	}
+}
+
+func (cb *circuitBreakerConfig) record(success bool, provider string) {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if success {
+		if cb.state == CircuitHalfOpen {
+			logger.DefaultLogger.Infof("circuit breaker for '%s' is closed", provider)
+		}
+		cb.state = CircuitClosed
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	threshold := cb.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	if cb.state == CircuitHalfOpen || cb.failures >= threshold {
+		if cb.state != CircuitOpen {
+			logger.DefaultLogger.Warnf("circuit breaker for '%s' is open after %d failures", provider, cb.failures)
+		}
+		cb.state = CircuitOpen
+		cb.openedAt = time.Now()
+	}
+}
+
+// release forgets the call that wasn't completed. A canceled trial call
+// returns the circuit to the open state, so the next call is a trial again.
+func (cb *circuitBreakerConfig) release() {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitHalfOpen {
+		cb.state = CircuitOpen
+	}
+}
+
+func (cb *circuitBreakerConfig) currentState() string {
+	if cb == nil {
+		return ""
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == "" {
+		return CircuitClosed
+	}
+	return cb.state
+}
+
+// CircuitBreakerState returns the state of the provider circuit breaker:
+// closed, open or half-open. It returns an empty string if the circuit breaker is not configured.
+// It implements providers.CircuitBreaker, the state is reported by the admin API.
+func (fh *FlexibleHTTP) CircuitBreakerState() string {
+	return fh.CircuitBreaker.currentState()
+}
+
+// do authorizes, signs and sends the rendered request with retries and records
+// the result in the circuit breaker. body is the request body to sign.
+// The caller is responsible for closing the body of the returned response.
+func (fh *FlexibleHTTP) do(ctx context.Context, req *http.Request, body []byte) (*http.Response, error) {
+	if err := fh.CircuitBreaker.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := fh.doWithRetry(ctx, req, body)
+	if ctx.Err() != nil || errors.Is(err, ErrInvalidRequestSchema) {
+		// the caller gave up or the request can't be signed,
+		// it says nothing about the health of the provider
+		fh.CircuitBreaker.release()
+		return resp, err
+	}
+	fh.CircuitBreaker.record(err == nil && !isRetryableStatus(resp.StatusCode), fh.Provider.URL)
+	return resp, err
+}
+
+// doWithRetry signs every attempt again, so timestamps of signatures
+// and expired auth tokens are refreshed before the request is retried.
+func (fh *FlexibleHTTP) doWithRetry(ctx context.Context, req *http.Request, body []byte) (*http.Response, error) {
+	attempts := fh.Retry.attempts()
+	for attempt := 1; ; attempt++ {
+		r := req.Clone(ctx)
+		if req.GetBody != nil {
+			b, err := req.GetBody()
+			if err != nil {
+				return nil, errors.Wrapf(ErrDataProviderIssue, "failed to copy request body: %v", err)
+			}
+			r.Body = b
+		}
+		if err := fh.authorizeAndSign(r, body); err != nil {
+			// failures to get an auth token are data provider issues
+			if errors.Is(err, ErrDataProviderIssue) {
+				return nil, err
+			}
+			return nil, errors.Wrap(ErrInvalidRequestSchema, err.Error())
+		}
+
+		resp, err := fh.httpcli.Do(r)
+		if err == nil && !isRetryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if attempt >= attempts || ctx.Err() != nil {
+			if err != nil {
+				return nil, errors.Wrapf(ErrDataProviderIssue, "failed http request: %v", err)
+			}
+			return resp, nil
+		}
+
+		delay := fh.Retry.backoff(attempt)
+		if err == nil {
+			if d, ok := retryAfter(resp); ok {
+				if d > fh.Retry.maxInterval() {
+					return resp, nil
+				}
+				delay = d
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			logger.DefaultLogger.Warnf("data provider '%s' returned status code '%d', retry in %s",
+				fh.Provider.URL, resp.StatusCode, delay)
+		} else {
+			logger.DefaultLogger.Warnf("request to data provider '%s' failed: %v, retry in %s",
+				fh.Provider.URL, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, errors.Wrapf(ErrDataProviderIssue, "failed http request: %v", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
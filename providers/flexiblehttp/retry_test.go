@@ -0,0 +1,253 @@
+package flexiblehttp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+var retryCredential = &verifiable.W3CCredential{
+	CredentialSubject: map[string]interface{}{
+		"address": "0x6ae7E07c8763C284B7C91371f934E46c766D0ec6",
+	},
+}
+
+func TestProvide_Retry(t *testing.T) {
+	tests := []struct {
+		name             string
+		responses        []int
+		retryAfter       string
+		expectedRequests int32
+		expectedError    error
+	}{
+		{
+			name:             "Success after server errors",
+			responses:        []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			expectedRequests: 3,
+		},
+		{
+			name:             "Too many requests with Retry-After",
+			responses:        []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:       "0",
+			expectedRequests: 2,
+		},
+		{
+			name:             "Retry-After longer than maxInterval",
+			responses:        []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:       "60",
+			expectedRequests: 1,
+			expectedError:    ErrDataProviderIssue,
+		},
+		{
+			name:             "Attempts are exhausted",
+			responses:        []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			expectedRequests: 3,
+			expectedError:    ErrDataProviderIssue,
+		},
+		{
+			name:             "Client errors are not retried",
+			responses:        []int{http.StatusNotFound, http.StatusOK},
+			expectedRequests: 1,
+			expectedError:    ErrDataProviderIssue,
+		},
+	}
+
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/retry.yaml", nil)
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := atomic.AddInt32(&requests, 1)
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.JSONEq(t, `{"address":"0x6ae7E07c8763C284B7C91371f934E46c766D0ec6"}`, string(body))
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.responses[i-1])
+				_, _ = w.Write([]byte(`{"balance": 42}`))
+			}))
+			defer srv.Close()
+
+			fh, err := factory.ProduceFlexibleHTTP("urn:test:Retry")
+			require.NoError(t, err)
+			fh.httpcli = srv.Client()
+			fh.Provider.URL = srv.URL
+
+			updatedFields, err := fh.Provide(context.Background(), retryCredential)
+			require.Equal(t, tt.expectedRequests, atomic.LoadInt32(&requests))
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, map[string]interface{}{"balance": 42}, updatedFields)
+		})
+	}
+}
+
+func TestProvide_RetrySigned(t *testing.T) {
+	t.Setenv("PROVIDER_TEST_HMAC_SECRET", "hmac-secret")
+	var now int64 = 1700000000
+	timeNow = func() time.Time { return time.Unix(atomic.AddInt64(&now, 1), 0) }
+	t.Cleanup(func() {
+		timeNow = time.Now
+	})
+
+	var timestamps []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		timestamp := r.Header.Get("X-Timestamp")
+		mac := hmac.New(sha256.New, []byte("hmac-secret"))
+		_, _ = fmt.Fprintf(mac, "POST\n/\n%s\n%s", timestamp, body)
+		require.Equal(t, hex.EncodeToString(mac.Sum(nil)), r.Header.Get("X-Signature"))
+
+		timestamps = append(timestamps, timestamp)
+		if len(timestamps) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"balance": 42}`))
+	}))
+	defer srv.Close()
+
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/retry.yaml", nil)
+	require.NoError(t, err)
+	fh, err := factory.ProduceFlexibleHTTP("urn:test:RetrySigned")
+	require.NoError(t, err)
+	fh.httpcli = srv.Client()
+	fh.Provider.URL = srv.URL
+
+	updatedFields, err := fh.Provide(context.Background(), retryCredential)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"balance": 42}, updatedFields)
+	require.Equal(t, []string{"1700000001", "1700000002", "1700000003"}, timestamps)
+}
+
+func TestProvide_RetryContextCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	fh := FlexibleHTTP{
+		httpcli:  srv.Client(),
+		Provider: provider{URL: srv.URL, Method: http.MethodGet},
+		Retry:    &retryConfig{Attempts: 5, InitialInterval: time.Hour},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := fh.Provide(ctx, retryCredential)
+	require.ErrorIs(t, err, ErrDataProviderIssue)
+	require.ErrorContains(t, err, context.DeadlineExceeded.Error())
+}
+
+func TestProvide_CircuitBreaker(t *testing.T) {
+	var (
+		requests int32
+		healthy  atomic.Bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"balance": 42}`))
+	}))
+	defer srv.Close()
+
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/retry.yaml", srv.Client())
+	require.NoError(t, err)
+	produce := func() FlexibleHTTP {
+		fh, err := factory.ProduceFlexibleHTTP("urn:test:CircuitBreaker")
+		require.NoError(t, err)
+		fh.Provider.URL = srv.URL
+		return fh
+	}
+
+	fh := produce()
+	require.Equal(t, CircuitClosed, fh.CircuitBreakerState())
+	for i := 0; i < 2; i++ {
+		_, err = fh.Provide(context.Background(), retryCredential)
+		require.ErrorIs(t, err, ErrDataProviderIssue)
+	}
+	// the state is shared between providers produced for the same credential type
+	fh = produce()
+	require.Equal(t, CircuitOpen, fh.CircuitBreakerState())
+	_, err = fh.Provide(context.Background(), retryCredential)
+	require.ErrorIs(t, err, ErrDataProviderIssue)
+	require.ErrorContains(t, err, "circuit breaker is open")
+	require.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// a failed trial call opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	_, err = fh.Provide(context.Background(), retryCredential)
+	require.ErrorIs(t, err, ErrDataProviderIssue)
+	require.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	require.Equal(t, CircuitOpen, fh.CircuitBreakerState())
+
+	// a successful trial call closes the circuit
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	updatedFields, err := fh.Provide(context.Background(), retryCredential)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"balance": 42}, updatedFields)
+	require.Equal(t, CircuitClosed, fh.CircuitBreakerState())
+}
+
+func TestProvide_CircuitBreakerContextCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	fh := FlexibleHTTP{
+		httpcli:        srv.Client(),
+		Provider:       provider{URL: srv.URL, Method: http.MethodGet},
+		CircuitBreaker: &circuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Hour},
+	}
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		_, err := fh.Provide(ctx, retryCredential)
+		cancel()
+		require.ErrorIs(t, err, ErrDataProviderIssue)
+	}
+	require.Equal(t, CircuitClosed, fh.CircuitBreakerState())
+
+	// a canceled trial call doesn't keep the circuit half-open
+	fh.CircuitBreaker.state = CircuitOpen
+	fh.CircuitBreaker.OpenTimeout = time.Nanosecond
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := fh.Provide(ctx, retryCredential)
+	require.ErrorIs(t, err, ErrDataProviderIssue)
+	require.Equal(t, CircuitOpen, fh.CircuitBreakerState())
+}
+
+func TestRetryBackoff(t *testing.T) {
+	rc := &retryConfig{InitialInterval: 100 * time.Millisecond, MaxInterval: time.Second, Multiplier: 2}
+	for attempt, expected := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		5: time.Second,
+	} {
+		d := rc.backoff(attempt)
+		require.GreaterOrEqual(t, d, expected/2)
+		require.LessOrEqual(t, d, expected)
+	}
+}
@@ -0,0 +1,268 @@
+package flexiblehttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	signingSchemeHMACSHA256 = "hmac-sha256"
+	signingSchemeAWSSigV4   = "aws-sigv4"
+)
+
+// timeNow is replaced in tests.
+var timeNow = time.Now
+
+// Signer signs the built request. body is the request body, it can be empty.
+type Signer interface {
+	Sign(request *http.Request, body []byte) error
+}
+
+// SignerConstructor creates a signer from the signing section of the provider configuration.
+type SignerConstructor func(cfg *SigningConfig) (Signer, error)
+
+var (
+	signersMu sync.RWMutex
+	signers   = map[string]SignerConstructor{
+		signingSchemeHMACSHA256: newHMACSigner,
+		signingSchemeAWSSigV4:   newAWSSigV4Signer,
+	}
+)
+
+// RegisterSigningScheme adds a custom signing scheme that can be used in the configuration.
+func RegisterSigningScheme(scheme string, constructor SignerConstructor) {
+	signersMu.Lock()
+	defer signersMu.Unlock()
+	signers[scheme] = constructor
+}
+
+// SigningConfig is the signing section of the provider configuration.
+type SigningConfig struct {
+	Scheme string `yaml:"scheme"`
+
+	// hmac-sha256
+	Secret          *secretRef `yaml:"secret"`
+	KeyID           *secretRef `yaml:"keyID"`
+	Header          string     `yaml:"header"`
+	TimestampHeader string     `yaml:"timestampHeader"`
+	KeyIDHeader     string     `yaml:"keyIDHeader"`
+	Encoding        string     `yaml:"encoding"`
+
+	// aws-sigv4
+	AccessKeyID     *secretRef `yaml:"accessKeyID"`
+	SecretAccessKey *secretRef `yaml:"secretAccessKey"`
+	SessionToken    *secretRef `yaml:"sessionToken"`
+	Region          string     `yaml:"region"`
+	Service         string     `yaml:"service"`
+
+	// Options are free-form options for custom signing schemes.
+	Options map[string]string `yaml:"options"`
+}
+
+// validate checks the secret references of the signing section.
+func (cfg *SigningConfig) validate() error {
+	return validateSecrets(map[string]*secretRef{
+		"secret":          cfg.Secret,
+		"keyID":           cfg.KeyID,
+		"accessKeyID":     cfg.AccessKeyID,
+		"secretAccessKey": cfg.SecretAccessKey,
+		"sessionToken":    cfg.SessionToken,
+	})
+}
+
+func (cfg *SigningConfig) signer() (Signer, error) {
+	signersMu.RLock()
+	constructor, ok := signers[cfg.Scheme]
+	signersMu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("unsupported signing scheme '%s'", cfg.Scheme)
+	}
+	return constructor(cfg)
+}
+
+// hmacSigner signs 'METHOD\nPATH?QUERY\nTIMESTAMP\nBODY' with HMAC-SHA256.
+type hmacSigner struct {
+	cfg *SigningConfig
+}
+
+func newHMACSigner(cfg *SigningConfig) (Signer, error) {
+	if cfg.Secret == nil {
+		return nil, errors.New("hmac secret is not defined")
+	}
+	return &hmacSigner{cfg: cfg}, nil
+}
+
+func (s *hmacSigner) Sign(request *http.Request, body []byte) error {
+	secret, err := s.cfg.Secret.resolve()
+	if err != nil {
+		return errors.Errorf("hmac secret: %v", err)
+	}
+	timestamp := strconv.FormatInt(timeNow().Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%s\n%s\n%s\n", request.Method, request.URL.RequestURI(), timestamp)
+	_, _ = mac.Write(body)
+
+	var signature string
+	switch s.cfg.Encoding {
+	case "hex", "":
+		signature = hex.EncodeToString(mac.Sum(nil))
+	case "base64":
+		signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	default:
+		return errors.Errorf("unsupported signature encoding '%s'", s.cfg.Encoding)
+	}
+
+	request.Header.Set(headerOrDefault(s.cfg.Header, "X-Signature"), signature)
+	request.Header.Set(headerOrDefault(s.cfg.TimestampHeader, "X-Timestamp"), timestamp)
+	if s.cfg.KeyID != nil {
+		keyID, err := s.cfg.KeyID.resolve()
+		if err != nil {
+			return errors.Errorf("hmac key id: %v", err)
+		}
+		request.Header.Set(headerOrDefault(s.cfg.KeyIDHeader, "X-Key-ID"), keyID)
+	}
+	return nil
+}
+
+func headerOrDefault(header, def string) string {
+	if header == "" {
+		return def
+	}
+	return header
+}
+
+// awsSigV4Signer signs requests with AWS Signature Version 4.
+type awsSigV4Signer struct {
+	cfg *SigningConfig
+}
+
+func newAWSSigV4Signer(cfg *SigningConfig) (Signer, error) {
+	if cfg.AccessKeyID == nil || cfg.SecretAccessKey == nil {
+		return nil, errors.New("aws access key is not defined")
+	}
+	if cfg.Region == "" || cfg.Service == "" {
+		return nil, errors.New("aws region and service are required")
+	}
+	return &awsSigV4Signer{cfg: cfg}, nil
+}
+
+func (s *awsSigV4Signer) Sign(request *http.Request, body []byte) error {
+	accessKeyID, err := s.cfg.AccessKeyID.resolve()
+	if err != nil {
+		return errors.Errorf("aws access key id: %v", err)
+	}
+	secretAccessKey, err := s.cfg.SecretAccessKey.resolve()
+	if err != nil {
+		return errors.Errorf("aws secret access key: %v", err)
+	}
+	sessionToken, err := s.cfg.SessionToken.resolve()
+	if err != nil {
+		return errors.Errorf("aws session token: %v", err)
+	}
+
+	now := timeNow().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	if sessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	headers := map[string]string{
+		"host":       request.URL.Host,
+		"x-amz-date": amzDate,
+	}
+	if sessionToken != "" {
+		headers["x-amz-security-token"] = sessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := strings.Builder{}
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		awsCanonicalURI(request.URL),
+		awsCanonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.cfg.Region, s.cfg.Service, "aws4_request"}, "/")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, s.cfg.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func awsCanonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func awsCanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(query))
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape escapes the string according to RFC 3986.
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,109 @@
+package flexiblehttp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withTime(t *testing.T, now time.Time) {
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() {
+		timeNow = time.Now
+	})
+}
+
+func TestSignHMAC(t *testing.T) {
+	t.Setenv("PROVIDER_TEST_HMAC_SECRET", "hmac-secret")
+	withTime(t, time.Unix(1700000000, 0))
+
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/signing.yaml", nil)
+	require.NoError(t, err)
+	provider, err := factory.ProduceFlexibleHTTP("urn:test:HMAC")
+	require.NoError(t, err)
+	request, err := provider.BuildRequest(context.Background(), TemplateData{
+		CredentialSubject: map[string]interface{}{"account": "42"},
+	})
+	require.NoError(t, err)
+
+	mac := hmac.New(sha256.New, []byte("hmac-secret"))
+	_, _ = mac.Write([]byte("POST\n/v1/balance?account=42\n1700000000\n{\"currency\":\"MATIC\"}"))
+	require.Equal(t, hex.EncodeToString(mac.Sum(nil)), request.Header.Get("X-Exchange-Signature"))
+	require.Equal(t, "1700000000", request.Header.Get("X-Timestamp"))
+	require.Equal(t, "key-1", request.Header.Get("X-Key-ID"))
+}
+
+func TestSignAWSSigV4(t *testing.T) {
+	// get-vanilla from the AWS Signature Version 4 test suite
+	t.Setenv("PROVIDER_TEST_AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	withTime(t, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/signing.yaml", nil)
+	require.NoError(t, err)
+	provider, err := factory.ProduceFlexibleHTTP("urn:test:AWSSigV4")
+	require.NoError(t, err)
+	request, err := provider.BuildRequest(context.Background(), TemplateData{})
+	require.NoError(t, err)
+
+	require.Equal(t, "20150830T123600Z", request.Header.Get("X-Amz-Date"))
+	require.Equal(t,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+			"SignedHeaders=host;x-amz-date, "+
+			"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		request.Header.Get("Authorization"))
+}
+
+func TestBuildRedactedRequest(t *testing.T) {
+	t.Setenv("PROVIDER_TEST_API_KEY", "api-key")
+	t.Setenv("PROVIDER_TEST_AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("PROVIDER_TEST_AWS_SESSION_TOKEN", "session-token")
+
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/signing.yaml", nil)
+	require.NoError(t, err)
+	provider, err := factory.ProduceFlexibleHTTP("urn:test:AWSSigV4Session")
+	require.NoError(t, err)
+	request, err := provider.BuildRedactedRequest(context.Background(), TemplateData{
+		CredentialSubject: map[string]interface{}{"account": "42"},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "account=42", request.URL.RawQuery)
+	require.Equal(t, "application/json", request.Header.Get("Accept"))
+	for _, header := range []string{"X-Api-Key", "X-Amz-Security-Token", "X-Amz-Date", "Authorization"} {
+		require.Equal(t, Redacted, request.Header.Get(header), header)
+	}
+}
+
+type staticSigner struct {
+	value string
+}
+
+func (s staticSigner) Sign(request *http.Request, _ []byte) error {
+	request.Header.Set("X-Signature", s.value)
+	return nil
+}
+
+func TestSignCustomScheme(t *testing.T) {
+	RegisterSigningScheme("test-static", func(cfg *SigningConfig) (Signer, error) {
+		return staticSigner{value: cfg.Options["value"]}, nil
+	})
+
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/signing.yaml", nil)
+	require.NoError(t, err)
+	provider, err := factory.ProduceFlexibleHTTP("urn:test:Custom")
+	require.NoError(t, err)
+	request, err := provider.BuildRequest(context.Background(), TemplateData{})
+	require.NoError(t, err)
+	require.Equal(t, "static-signature", request.Header.Get("X-Signature"))
+
+	provider, err = factory.ProduceFlexibleHTTP("urn:test:Unknown")
+	require.NoError(t, err)
+	_, err = provider.BuildRequest(context.Background(), TemplateData{})
+	require.Error(t, err)
+}
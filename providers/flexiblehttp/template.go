@@ -0,0 +1,287 @@
+package flexiblehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+var singleAction = regexp.MustCompile(`^{{-?\s*(.*?)\s*-?}}$`)
+
+// TemplateData is the data available to templates in the provider configuration.
+// Every field is available as a function (`{{ credentialSubject.address }}`)
+// and as a field of the dot (`{{ .credentialSubject.address }}`).
+type TemplateData struct {
+	// CredentialSubject is the subject of the credential that is being refreshed.
+	CredentialSubject map[string]interface{}
+	// Credential is the JSON representation of the credential that is being refreshed.
+	Credential map[string]interface{}
+	// DID is the DID of the holder that requested the refresh.
+	DID string
+}
+
+// NewTemplateData builds template data from the credential and the requesting DID.
+func NewTemplateData(credential *verifiable.W3CCredential, did string) (TemplateData, error) {
+	credentialBytes, err := json.Marshal(credential)
+	if err != nil {
+		return TemplateData{}, errors.Errorf("failed to marshal credential: %v", err)
+	}
+	credentialMap := make(map[string]interface{})
+	if err := json.Unmarshal(credentialBytes, &credentialMap); err != nil {
+		return TemplateData{}, errors.Errorf("failed to unmarshal credential: %v", err)
+	}
+	return TemplateData{
+		CredentialSubject: credential.CredentialSubject,
+		Credential:        credentialMap,
+		DID:               did,
+	}, nil
+}
+
+func (td TemplateData) dot() map[string]interface{} {
+	return map[string]interface{}{
+		"credentialSubject": td.CredentialSubject,
+		"credential":        td.Credential,
+		"did":               td.DID,
+	}
+}
+
+func (td TemplateData) funcs() template.FuncMap {
+	return template.FuncMap{
+		"credentialSubject": func() map[string]interface{} { return td.CredentialSubject },
+		"credential":        func() map[string]interface{} { return td.Credential },
+		"did":               func() string { return td.DID },
+
+		"env":        envValue,
+		"secret":     secretValue,
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"trim":       strings.TrimSpace,
+		"hex":        toHex,
+		"decimal":    toDecimal,
+		"formatDate": formatDate,
+		"unix":       toUnix,
+		"now":        time.Now,
+		"urlEscape":  url.QueryEscape,
+		"pathEscape": url.PathEscape,
+		"toJSON":     toJSON,
+		"default":    defaultValue,
+	}
+}
+
+func (td TemplateData) parse(text string) (*template.Template, error) {
+	t, err := template.New("").
+		Option("missingkey=error").
+		Funcs(td.funcs()).
+		Parse(text)
+	if err != nil {
+		return nil, errors.Errorf("invalid template '%s': %v", text, err)
+	}
+	return t, nil
+}
+
+// Render executes the text as a template and returns the result as a string.
+func (td TemplateData) Render(text string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	t, err := td.parse(text)
+	if err != nil {
+		return "", err
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := t.Execute(buf, td.dot()); err != nil {
+		return "", errors.Errorf("failed to execute template '%s': %v", text, err)
+	}
+	return buf.String(), nil
+}
+
+// Value executes the text as a template. If the text consists of a single action,
+// the raw result of the action is returned, so numbers and objects keep their type.
+func (td TemplateData) Value(text string) (interface{}, error) {
+	m := singleAction.FindStringSubmatch(text)
+	if m == nil || strings.Contains(m[1], "{{") {
+		return td.Render(text)
+	}
+
+	var captured interface{}
+	funcs := td.funcs()
+	funcs["__capture"] = func(v interface{}) string {
+		captured = v
+		return ""
+	}
+	captureText := fmt.Sprintf("{{ __capture (%s) }}", m[1])
+	t, err := template.New("").
+		Option("missingkey=error").
+		Funcs(funcs).
+		Parse(captureText)
+	if err != nil {
+		return nil, errors.Errorf("invalid template '%s': %v", text, err)
+	}
+	if err := t.Execute(bytes.NewBuffer(nil), td.dot()); err != nil {
+		return nil, errors.Errorf("failed to execute template '%s': %v", text, err)
+	}
+	return captured, nil
+}
+
+// EnvPrefix is the required prefix of environment variables available to templates,
+// so templates can't send the configuration of the service to data providers.
+const EnvPrefix = "PROVIDER_"
+
+func checkEnvName(name string) error {
+	if !strings.HasPrefix(name, EnvPrefix) {
+		return errors.Errorf("environment variable '%s' is not allowed, the name must start with '%s'",
+			name, EnvPrefix)
+	}
+	return nil
+}
+
+func envValue(name string) (string, error) {
+	if err := checkEnvName(name); err != nil {
+		return "", err
+	}
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", errors.Errorf("environment variable '%s' is not set", name)
+	}
+	return v, nil
+}
+
+// secretValue returns the value of the environment variable, or the content of
+// the file referenced by the <name>_FILE environment variable.
+func secretValue(name string) (string, error) {
+	if err := checkEnvName(name); err != nil {
+		return "", err
+	}
+	if v, ok := os.LookupEnv(name); ok {
+		return v, nil
+	}
+	path, ok := os.LookupEnv(name + "_FILE")
+	if !ok {
+		return "", errors.Errorf("secret '%s' is not set", name)
+	}
+	//nolint:gosec // the path is defined by the operator of the service
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.Errorf("failed to read secret '%s': %v", name, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func toBigInt(v interface{}) (*big.Int, error) {
+	switch v := v.(type) {
+	case string:
+		n, ok := new(big.Int).SetString(v, 0)
+		if !ok {
+			return nil, errors.Errorf("'%s' is not an integer", v)
+		}
+		return n, nil
+	case float64:
+		n, _ := big.NewFloat(v).Int(nil)
+		return n, nil
+	case int:
+		return big.NewInt(int64(v)), nil
+	case int64:
+		return big.NewInt(v), nil
+	case *big.Int:
+		return v, nil
+	default:
+		return nil, errors.Errorf("'%v' of type '%T' is not an integer", v, v)
+	}
+}
+
+func toHex(v interface{}) (string, error) {
+	n, err := toBigInt(v)
+	if err != nil {
+		return "", err
+	}
+	return "0x" + n.Text(16), nil
+}
+
+// toDecimal converts an integer or a 0x-prefixed hex string to a decimal string.
+// Strings without the prefix are parsed as decimal numbers.
+func toDecimal(v interface{}) (string, error) {
+	if s, ok := v.(string); ok && !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		n, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return "", errors.Errorf("'%s' is not an integer", s)
+		}
+		return n.String(), nil
+	}
+	n, err := toBigInt(v)
+	if err != nil {
+		return "", err
+	}
+	return n.String(), nil
+}
+
+func toTime(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, errors.New("time is nil")
+		}
+		return *v, nil
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		if err == nil {
+			return t, nil
+		}
+		unix, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return time.Time{}, errors.Errorf("'%s' is not a RFC3339 date or unix timestamp", v)
+		}
+		return time.Unix(unix, 0).UTC(), nil
+	case float64:
+		return time.Unix(int64(v), 0).UTC(), nil
+	case int:
+		return time.Unix(int64(v), 0).UTC(), nil
+	case int64:
+		return time.Unix(v, 0).UTC(), nil
+	default:
+		return time.Time{}, errors.Errorf("'%v' of type '%T' is not a date", v, v)
+	}
+}
+
+func formatDate(layout string, v interface{}) (string, error) {
+	t, err := toTime(v)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(layout), nil
+}
+
+func toUnix(v interface{}) (int64, error) {
+	t, err := toTime(v)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func defaultValue(def, v interface{}) interface{} {
+	if v == nil || v == "" {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,175 @@
+package flexiblehttp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateDataRender(t *testing.T) {
+	t.Setenv("PROVIDER_API_KEY", "secret-key")
+	expiration := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	data, err := NewTemplateData(&verifiable.W3CCredential{
+		ID:         "urn:uuid:e342def6-620e-4394-8ea1-7448ea81bb72",
+		Issuer:     "did:polygonid:polygon:amoy:2qQ68JkRcf3xrHPQPWZei3YeVzHPP58wYNxx2mEouR",
+		Expiration: &expiration,
+		CredentialSchema: verifiable.CredentialSchema{
+			ID:   "https://example.com/schema.json",
+			Type: "JsonSchema2023",
+		},
+		CredentialSubject: map[string]interface{}{
+			"address": "0x6AE7E07C8763C284B7C91371F934E46C766D0EC6",
+			"wallet": map[string]interface{}{
+				"chainId": float64(80002),
+			},
+			"name": "John Doe",
+		},
+	}, "did:polygonid:polygon:amoy:2qV9QXdhXXmN5sKjN1YueMjxgRbnJcEGK2kGpvk3cq")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "Legacy placeholder",
+			template: "{{ credentialSubject.address }}",
+			expected: "0x6AE7E07C8763C284B7C91371F934E46C766D0EC6",
+		},
+		{
+			name:     "Dot notation inside text",
+			template: "/wallets/{{ .credentialSubject.address }}/balance",
+			expected: "/wallets/0x6AE7E07C8763C284B7C91371F934E46C766D0EC6/balance",
+		},
+		{
+			name:     "Nested field with hex conversion",
+			template: "{{ hex credentialSubject.wallet.chainId }}",
+			expected: "0x13882",
+		},
+		{
+			name:     "Decimal conversion",
+			template: "{{ decimal \"0x13882\" }}",
+			expected: "80002",
+		},
+		{
+			name:     "Lowercase",
+			template: "{{ credentialSubject.address | lower }}",
+			expected: "0x6ae7e07c8763c284b7c91371f934e46c766d0ec6",
+		},
+		{
+			name:     "Credential metadata",
+			template: "{{ credential.issuer }} {{ credential.credentialSchema.id }}",
+			expected: "did:polygonid:polygon:amoy:2qQ68JkRcf3xrHPQPWZei3YeVzHPP58wYNxx2mEouR https://example.com/schema.json",
+		},
+		{
+			name:     "Date formatting",
+			template: "{{ formatDate \"20060102\" credential.expirationDate }}",
+			expected: "20240315",
+		},
+		{
+			name:     "Requesting DID",
+			template: "{{ did }}",
+			expected: "did:polygonid:polygon:amoy:2qV9QXdhXXmN5sKjN1YueMjxgRbnJcEGK2kGpvk3cq",
+		},
+		{
+			name:     "Decimal string",
+			template: "{{ decimal \"100\" }}",
+			expected: "100",
+		},
+		{
+			name:     "Decimal of a number",
+			template: "{{ decimal 100 }}",
+			expected: "100",
+		},
+		{
+			name:     "Environment secret",
+			template: "Bearer {{ env \"PROVIDER_API_KEY\" }}",
+			expected: "Bearer secret-key",
+		},
+		{
+			name:     "URL escaping",
+			template: "{{ urlEscape credentialSubject.name }}",
+			expected: "John+Doe",
+		},
+		{
+			name:     "Plain text",
+			template: "balance",
+			expected: "balance",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := data.Render(tt.template)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestTemplateDataRender_Error(t *testing.T) {
+	data := TemplateData{
+		CredentialSubject: map[string]interface{}{
+			"address": "0x6ae7E07c8763C284B7C91371f934E46c766D0ec6",
+		},
+	}
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{
+			name:     "Missing field",
+			template: "{{ credentialSubject.currency }}",
+		},
+		{
+			name:     "Unknown function",
+			template: "{{ unknown credentialSubject.address }}",
+		},
+		{
+			name:     "Not set environment variable",
+			template: "{{ env \"PROVIDER_NOT_SET_VARIABLE\" }}",
+		},
+		{
+			name:     "Environment variable without the prefix",
+			template: "{{ env \"ADMIN_TOKEN\" }}",
+		},
+		{
+			name:     "Secret without the prefix",
+			template: "{{ secret \"ISSUERS_BASIC_AUTH\" }}",
+		},
+		{
+			name:     "Invalid decimal string",
+			template: "{{ decimal \"1a\" }}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := data.Render(tt.template)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestTemplateDataValue(t *testing.T) {
+	data := TemplateData{
+		CredentialSubject: map[string]interface{}{
+			"age":     float64(21),
+			"address": "0x6ae7E07c8763C284B7C91371f934E46c766D0ec6",
+		},
+	}
+	v, err := data.Value("{{ credentialSubject.age }}")
+	require.NoError(t, err)
+	require.Equal(t, float64(21), v)
+
+	v, err = data.Value("{{ credentialSubject.address | upper }}")
+	require.NoError(t, err)
+	require.Equal(t, "0X6AE7E07C8763C284B7C91371F934E46C766D0EC6", v)
+
+	v, err = data.Value("age: {{ credentialSubject.age }}")
+	require.NoError(t, err)
+	require.Equal(t, "age: 21", v)
+}
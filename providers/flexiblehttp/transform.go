@@ -0,0 +1,243 @@
+package flexiblehttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// maxFractionDigits is the precision of non-integer results of arithmetic steps.
+const maxFractionDigits = 18
+
+// transformStep is a step of the property transformation pipeline.
+// In the configuration a step is an object with a single key, e.g. `divide: 1e18`.
+type transformStep struct {
+	op string
+
+	number *big.Rat
+	places int
+	text   string
+	enum   map[string]interface{}
+}
+
+func (s *transformStep) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		// steps without arguments can be written as a plain string
+		s.op = node.Value
+		return s.validateNoArg()
+	}
+	if node.Kind != yaml.MappingNode || len(node.Content) != 2 {
+		return errors.Errorf("line %d: transform step should be an object with a single key", node.Line)
+	}
+	s.op = node.Content[0].Value
+	arg := node.Content[1]
+
+	switch s.op {
+	case "add", "subtract", "multiply", "divide", "gt", "gte", "lt", "lte":
+		n, ok := new(big.Rat).SetString(arg.Value)
+		if !ok {
+			return errors.Errorf("line %d: '%s' argument should be a number, got '%s'", arg.Line, s.op, arg.Value)
+		}
+		if s.op == "divide" && n.Sign() == 0 {
+			return errors.Errorf("line %d: division by zero", arg.Line)
+		}
+		s.number = n
+	case "round", "floor", "ceil":
+		places, err := strconv.Atoi(arg.Value)
+		if err != nil || places < 0 {
+			return errors.Errorf("line %d: '%s' argument should be a number of decimal places, got '%s'",
+				arg.Line, s.op, arg.Value)
+		}
+		s.places = places
+	case "eq", "neq", "format", "date":
+		s.text = arg.Value
+	case "enum":
+		if err := arg.Decode(&s.enum); err != nil {
+			return errors.Errorf("line %d: 'enum' argument should be an object: %v", arg.Line, err)
+		}
+	default:
+		if err := s.validateNoArg(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *transformStep) validateNoArg() error {
+	switch s.op {
+	case "round", "floor", "ceil", "unix", "yyyymmdd", "lower", "upper", "trim":
+		return nil
+	default:
+		return errors.Errorf("unsupported transform step '%s'", s.op)
+	}
+}
+
+// transform applies the steps to the value in order.
+func transform(v interface{}, steps []transformStep) (interface{}, error) {
+	var err error
+	for _, s := range steps {
+		v, err = s.apply(v)
+		if err != nil {
+			return nil, errors.Errorf("transform '%s': %v", s.op, err)
+		}
+	}
+	return v, nil
+}
+
+func (s *transformStep) apply(v interface{}) (interface{}, error) {
+	switch s.op {
+	case "add", "subtract", "multiply", "divide":
+		n, err := toRat(v)
+		if err != nil {
+			return nil, err
+		}
+		switch s.op {
+		case "add":
+			n.Add(n, s.number)
+		case "subtract":
+			n.Sub(n, s.number)
+		case "multiply":
+			n.Mul(n, s.number)
+		case "divide":
+			n.Quo(n, s.number)
+		}
+		return ratToString(n), nil
+	case "round", "floor", "ceil":
+		n, err := toRat(v)
+		if err != nil {
+			return nil, err
+		}
+		return ratToString(roundRat(n, s.places, s.op)), nil
+	case "gt", "gte", "lt", "lte":
+		n, err := toRat(v)
+		if err != nil {
+			return nil, err
+		}
+		c := n.Cmp(s.number)
+		switch s.op {
+		case "gt":
+			return c > 0, nil
+		case "gte":
+			return c >= 0, nil
+		case "lt":
+			return c < 0, nil
+		default:
+			return c <= 0, nil
+		}
+	case "eq", "neq":
+		equal := fmt.Sprint(v) == s.text
+		if n, err := toRat(v); err == nil {
+			if arg, ok := new(big.Rat).SetString(s.text); ok {
+				equal = n.Cmp(arg) == 0
+			}
+		}
+		return equal == (s.op == "eq"), nil
+	case "format":
+		return fmt.Sprintf(s.text, v), nil
+	case "lower":
+		return strings.ToLower(fmt.Sprint(v)), nil
+	case "upper":
+		return strings.ToUpper(fmt.Sprint(v)), nil
+	case "trim":
+		return strings.TrimSpace(fmt.Sprint(v)), nil
+	case "date":
+		str, ok := v.(string)
+		if !ok {
+			return nil, errors.Errorf("'%v' of type '%T' is not a string", v, v)
+		}
+		t, err := time.Parse(s.text, str)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	case "unix":
+		return toUnix(v)
+	case "yyyymmdd":
+		t, err := toTime(v)
+		if err != nil {
+			return nil, err
+		}
+		return t.Year()*10000 + int(t.Month())*100 + t.Day(), nil
+	case "enum":
+		mapped, ok := s.enum[fmt.Sprint(v)]
+		if !ok {
+			mapped, ok = s.enum["*"]
+		}
+		if !ok {
+			return nil, errors.Errorf("value '%v' is not in enum", v)
+		}
+		return mapped, nil
+	default:
+		return nil, errors.Errorf("unsupported transform step '%s'", s.op)
+	}
+}
+
+func toRat(v interface{}) (*big.Rat, error) {
+	if n, ok := v.(json.Number); ok {
+		v = string(n)
+	}
+	switch v := v.(type) {
+	case string:
+		n, ok := new(big.Rat).SetString(strings.TrimSpace(v))
+		if !ok {
+			return nil, errors.Errorf("'%s' is not a number", v)
+		}
+		return n, nil
+	case float64:
+		n := new(big.Rat)
+		if n.SetFloat64(v) == nil {
+			return nil, errors.Errorf("'%v' is not a finite number", v)
+		}
+		return n, nil
+	case int:
+		return new(big.Rat).SetInt64(int64(v)), nil
+	case int64:
+		return new(big.Rat).SetInt64(v), nil
+	default:
+		return nil, errors.Errorf("'%v' of type '%T' is not a number", v, v)
+	}
+}
+
+// ratToString formats the number as a decimal string without trailing zeros.
+func ratToString(n *big.Rat) string {
+	if n.IsInt() {
+		return n.Num().String()
+	}
+	s := n.FloatString(maxFractionDigits)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// roundRat rounds the number to the decimal places. mode is round (half away from zero), floor or ceil.
+func roundRat(n *big.Rat, places int, mode string) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+	scaled := new(big.Rat).Mul(n, new(big.Rat).SetInt(scale))
+
+	// quotient and remainder of the scaled number, the remainder has the sign of the number
+	q, r := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if r.Sign() != 0 {
+		switch mode {
+		case "floor":
+			if r.Sign() < 0 {
+				q.Sub(q, big.NewInt(1))
+			}
+		case "ceil":
+			if r.Sign() > 0 {
+				q.Add(q, big.NewInt(1))
+			}
+		default:
+			// round half away from zero: |2r| >= denom
+			twice := new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2))
+			if twice.Cmp(scaled.Denom()) >= 0 {
+				q.Add(q, big.NewInt(int64(r.Sign())))
+			}
+		}
+	}
+	return new(big.Rat).SetFrac(q, scale)
+}
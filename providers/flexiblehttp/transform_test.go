@@ -0,0 +1,163 @@
+package flexiblehttp
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const transformResponse = `{
+	"result": "1234567890000000000000",
+	"fee": 0.123,
+	"status": "active",
+	"level": "gold",
+	"birthday": "24/04/1996",
+	"updatedAt": "2024-01-31T10:00:00Z"
+}`
+
+func TestDecodeResponse_Transform(t *testing.T) {
+	tests := []struct {
+		name                  string
+		credentialType        string
+		expectedUpdatedFields map[string]interface{}
+	}{
+		{
+			name:           "Arithmetic and rounding",
+			credentialType: "urn:test:Arithmetic",
+			expectedUpdatedFields: map[string]interface{}{
+				"balance": "1234.56789",
+				"rounded": 1234.57,
+				"fee":     13,
+			},
+		},
+		{
+			name:           "Thresholds",
+			credentialType: "urn:test:Threshold",
+			expectedUpdatedFields: map[string]interface{}{
+				"whale":  true,
+				"active": true,
+			},
+		},
+		{
+			name:           "Dates",
+			credentialType: "urn:test:Dates",
+			expectedUpdatedFields: map[string]interface{}{
+				"birthday":     19960424,
+				"updatedAt":    1706695200,
+				"birthdayDate": "1996-04-24T00:00:00Z",
+			},
+		},
+		{
+			name:           "Format and enum",
+			credentialType: "urn:test:FormatAndEnum",
+			expectedUpdatedFields: map[string]interface{}{
+				"status": 1,
+				"level":  "LEVEL-GOLD",
+			},
+		},
+		{
+			name:           "Default values",
+			credentialType: "urn:test:Default",
+			expectedUpdatedFields: map[string]interface{}{
+				"score": 10,
+				"tier":  "BASIC",
+			},
+		},
+	}
+
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/transform.yaml", nil)
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := factory.ProduceFlexibleHTTP(tt.credentialType)
+			require.NoError(t, err)
+			var response interface{}
+			require.NoError(t, json.Unmarshal([]byte(transformResponse), &response))
+			updatedFields, err := provider.DecodeResponse(response)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedUpdatedFields, updatedFields)
+		})
+	}
+}
+
+func TestDecodeResponse_TransformError(t *testing.T) {
+	tests := []struct {
+		name           string
+		credentialType string
+		expectedError  string
+	}{
+		{
+			name:           "Arithmetic on a string",
+			credentialType: "urn:test:NotANumber",
+			expectedError:  "'active' is not a number",
+		},
+		{
+			name:           "Value is not in enum",
+			credentialType: "urn:test:NotInEnum",
+			expectedError:  "value 'gold' is not in enum",
+		},
+	}
+
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/transform.yaml", nil)
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := factory.ProduceFlexibleHTTP(tt.credentialType)
+			require.NoError(t, err)
+			var response interface{}
+			require.NoError(t, json.Unmarshal([]byte(transformResponse), &response))
+			_, err = provider.DecodeResponse(response)
+			require.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestTransformStep_Invalid(t *testing.T) {
+	tests := []struct {
+		name          string
+		step          string
+		expectedError string
+	}{
+		{name: "Unknown step", step: "sqrt: 2", expectedError: "unsupported transform step 'sqrt'"},
+		{name: "Unknown plain step", step: "sqrt", expectedError: "unsupported transform step 'sqrt'"},
+		{name: "Not a number", step: "divide: wei", expectedError: "argument should be a number"},
+		{name: "Division by zero", step: "divide: 0", expectedError: "division by zero"},
+		{name: "Negative places", step: "round: -1", expectedError: "number of decimal places"},
+		{name: "Several keys", step: "{add: 1, subtract: 2}", expectedError: "single key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var step transformStep
+			err := yaml.Unmarshal([]byte(tt.step), &step)
+			require.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestRoundRat(t *testing.T) {
+	tests := []struct {
+		value    string
+		places   int
+		mode     string
+		expected string
+	}{
+		{value: "1.005", places: 2, mode: "round", expected: "1.01"},
+		{value: "-1.005", places: 2, mode: "round", expected: "-1.01"},
+		{value: "1.004", places: 2, mode: "round", expected: "1"},
+		{value: "2.5", places: 0, mode: "round", expected: "3"},
+		{value: "1.999", places: 1, mode: "floor", expected: "1.9"},
+		{value: "-1.01", places: 1, mode: "floor", expected: "-1.1"},
+		{value: "1.01", places: 1, mode: "ceil", expected: "1.1"},
+		{value: "-1.09", places: 1, mode: "ceil", expected: "-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.value, func(t *testing.T) {
+			n, ok := new(big.Rat).SetString(tt.value)
+			require.True(t, ok)
+			require.Equal(t, tt.expected, ratToString(roundRat(n, tt.places, tt.mode)))
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package flexiblehttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// transportConfig is the transport section of the provider configuration.
+type transportConfig struct {
+	Timeout time.Duration `yaml:"timeout"`
+	Proxy   string        `yaml:"proxy"`
+	TLS     *tlsConfig    `yaml:"tls"`
+}
+
+type tlsConfig struct {
+	CertFile   string `yaml:"certFile"`
+	KeyFile    string `yaml:"keyFile"`
+	CAFile     string `yaml:"caFile"`
+	MinVersion string `yaml:"minVersion"`
+	ServerName string `yaml:"serverName"`
+}
+
+// client builds a dedicated http client for the provider.
+// The transport of the base client is used as a template if it is an *http.Transport.
+func (tc *transportConfig) client(base *http.Client) (*http.Client, error) {
+	var transport *http.Transport
+	if t, ok := base.Transport.(*http.Transport); ok {
+		transport = t.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	if tc.Proxy != "" {
+		proxyURL, err := url.Parse(tc.Proxy)
+		if err != nil {
+			return nil, errors.Errorf("invalid proxy url: %v", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if tc.TLS != nil {
+		tlsCfg, err := tc.TLS.config(transport.TLSClientConfig)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsCfg
+	}
+
+	return &http.Client{
+		Transport:     transport,
+		Timeout:       tc.Timeout,
+		CheckRedirect: base.CheckRedirect,
+		Jar:           base.Jar,
+	}, nil
+}
+
+func (tc *tlsConfig) config(base *tls.Config) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if base != nil {
+		cfg = base.Clone()
+	}
+
+	if tc.MinVersion != "" {
+		v, ok := tlsVersions[tc.MinVersion]
+		if !ok {
+			return nil, errors.Errorf("unsupported tls version '%s'", tc.MinVersion)
+		}
+		cfg.MinVersion = v
+	}
+	if tc.ServerName != "" {
+		cfg.ServerName = tc.ServerName
+	}
+
+	if tc.CertFile != "" || tc.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+		if err != nil {
+			return nil, errors.Errorf("failed to load client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if tc.CAFile != "" {
+		//nolint:gosec // the path is defined by the operator of the service
+		ca, err := os.ReadFile(tc.CAFile)
+		if err != nil {
+			return nil, errors.Errorf("failed to read ca file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("no certificates found in ca file '%s'", tc.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
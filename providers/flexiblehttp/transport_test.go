@@ -0,0 +1,171 @@
+package flexiblehttp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(t *testing.T, dir, name string) (certFile, keyFile string) {
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600))
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(keyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestTransportMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(time.Hour)
+	ca := newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	server := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "provider"},
+		NotAfter:     notAfter,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "refresh-service"},
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := client.write(t, dir, "client")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"owner": %q}`, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	srv.TLS = &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{server.der},
+			PrivateKey:  server.key,
+		}},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	configPath := filepath.Join(dir, "config.yaml")
+	config := fmt.Sprintf(`
+urn:test:MutualTLS:
+  provider:
+    url: %[1]s
+    method: GET
+  transport:
+    timeout: 5s
+    tls:
+      certFile: %[2]s
+      keyFile: %[3]s
+      caFile: %[4]s
+      minVersion: "1.3"
+  responseSchema:
+    properties:
+      owner:
+        type: string
+        match: credentialSubject.owner
+urn:test:WithoutClientCert:
+  provider:
+    url: %[1]s
+    method: GET
+  transport:
+    tls:
+      caFile: %[4]s
+urn:test:Proxy:
+  provider:
+    url: %[1]s
+    method: GET
+  transport:
+    proxy: http://127.0.0.1:3128
+`, srv.URL, certFile, keyFile, caFile)
+	require.NoError(t, os.WriteFile(configPath, []byte(config), 0o600))
+
+	factory, err := NewFactoryFlexibleHTTP(configPath, nil)
+	require.NoError(t, err)
+
+	provider, err := factory.ProduceFlexibleHTTP("urn:test:MutualTLS")
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Second, provider.httpcli.Timeout)
+	fields, err := provider.Provide(context.Background(), &verifiable.W3CCredential{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"owner": "refresh-service"}, fields)
+
+	provider, err = factory.ProduceFlexibleHTTP("urn:test:WithoutClientCert")
+	require.NoError(t, err)
+	_, err = provider.Provide(context.Background(), &verifiable.W3CCredential{})
+	require.ErrorIs(t, err, ErrDataProviderIssue)
+
+	provider, err = factory.ProduceFlexibleHTTP("urn:test:Proxy")
+	require.NoError(t, err)
+	proxy, err := provider.httpcli.Transport.(*http.Transport).Proxy(&http.Request{})
+	require.NoError(t, err)
+	require.Equal(t, "http://127.0.0.1:3128", proxy.String())
+}
+
+func TestTransport_Error(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+urn:test:InvalidTLS:
+  provider:
+    url: https://example.com
+    method: GET
+  transport:
+    tls:
+      minVersion: "2.0"
+`), 0o600))
+	_, err := NewFactoryFlexibleHTTP(configPath, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,161 @@
+package flexiblehttp
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxSafeInteger is the largest integer that float64 represents exactly.
+const maxSafeInteger = 1 << 53
+
+// castToExtendedType converts the value to the types that keep precision
+// and match JSON-LD xsd types:
+//   - bigint: xsd:integer of any size, encoded as a decimal string;
+//   - decimal-string: xsd:decimal, encoded as a decimal string;
+//   - positive-integer: xsd:positiveInteger of any size, encoded as a decimal string like bigint;
+//   - date: xsd:date, encoded as 'YYYY-MM-DD';
+//   - datetime: xsd:dateTime, encoded as RFC3339.
+func castToExtendedType(v interface{}, toType string) (interface{}, error) {
+	switch toType {
+	case "bigint":
+		n, err := toExactInt(v)
+		if err != nil {
+			return nil, err
+		}
+		return n.String(), nil
+	case "decimal-string":
+		n, err := toRat(v)
+		if err != nil {
+			return nil, err
+		}
+		return ratToString(n), nil
+	case "positive-integer":
+		n, err := toExactInt(v)
+		if err != nil {
+			return nil, err
+		}
+		if n.Sign() <= 0 {
+			return nil, errors.Errorf("'%s' is not a positive integer", n)
+		}
+		return n.String(), nil
+	case "date":
+		t, err := toTime(dateValue(v))
+		if err != nil {
+			return nil, err
+		}
+		return t.Format(time.DateOnly), nil
+	case "datetime":
+		t, err := toTime(dateValue(v))
+		if err != nil {
+			return nil, err
+		}
+		return t.Format(time.RFC3339), nil
+	default:
+		return nil, errors.Errorf("unsupported type '%s'", toType)
+	}
+}
+
+// castToComposite keeps objects and arrays as is, so they can be mapped to nested properties.
+func castToComposite(v interface{}, toType string) (interface{}, error) {
+	switch v.(type) {
+	case map[string]interface{}:
+		if toType == "object" {
+			return v, nil
+		}
+	case []interface{}:
+		if toType == "array" {
+			return v, nil
+		}
+	}
+	return nil, errors.Errorf("not possible convert '%T' to '%s'", v, toType)
+}
+
+// toExactInt converts the value to an integer without losing precision.
+// Numbers with a fractional part are rejected.
+func toExactInt(v interface{}) (*big.Int, error) {
+	n, err := toRat(v)
+	if err != nil {
+		return nil, err
+	}
+	if !n.IsInt() {
+		return nil, errors.Errorf("'%s' is not an integer", ratToString(n))
+	}
+	return new(big.Int).Set(n.Num()), nil
+}
+
+func numberValue(v interface{}) interface{} {
+	if n, ok := v.(json.Number); ok {
+		return string(n)
+	}
+	return v
+}
+
+// dateValue accepts a date without time in addition to values supported by toTime.
+func dateValue(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return numberValue(v)
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t
+	}
+	return s
+}
+
+// normalizeNumbers converts JSON numbers to float64, except integers that
+// float64 can't represent exactly. They are kept as json.Number.
+func normalizeNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalizeNumbers(e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeNumbers(e)
+		}
+		return v
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return v
+		}
+		if math.Abs(f) > maxSafeInteger && !strings.ContainsAny(string(v), ".eE") {
+			return v
+		}
+		return f
+	default:
+		return v
+	}
+}
+
+// setField sets the value at the path, creating nested objects.
+// A property can be set only once.
+func setField(fields map[string]interface{}, path []string, v interface{}) error {
+	for i, name := range path[:len(path)-1] {
+		next, ok := fields[name]
+		if !ok {
+			nested := make(map[string]interface{})
+			fields[name] = nested
+			fields = nested
+			continue
+		}
+		nested, ok := next.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("'%s' is not an object", strings.Join(path[:i+1], "."))
+		}
+		fields = nested
+	}
+	name := path[len(path)-1]
+	if _, ok := fields[name]; ok {
+		return errors.Errorf("'%s' is matched by several properties", strings.Join(path, "."))
+	}
+	fields[name] = v
+	return nil
+}
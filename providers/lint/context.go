@@ -0,0 +1,87 @@
+package lint
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// lintContext checks that the mapped fields are defined in the JSON-LD context
+// of the credential type. The credential type should be '<context URL>#<type>'.
+// Problems with resolving the context are warnings, since the context can be
+// unavailable at startup.
+func (l *linter) lintContext(credentialType string, targets []string) (issues, warnings []error) {
+	contextURL, typeName, ok := strings.Cut(credentialType, "#")
+	if !ok || !strings.Contains(contextURL, "://") {
+		return nil, []error{errors.New("the JSON-LD context is not checked, " +
+			"the credential type is not '<context URL>#<type>'")}
+	}
+	document, err := l.documentLoader.LoadDocument(contextURL)
+	if err != nil {
+		return nil, []error{errors.Errorf("failed to load JSON-LD context '%s': %v", contextURL, err)}
+	}
+	root, ok := document.Document.(map[string]interface{})
+	if !ok {
+		return nil, []error{errors.Errorf("invalid JSON-LD context '%s'", contextURL)}
+	}
+
+	typeDefinition, ok := findTerm(root["@context"], typeName, credentialType)
+	if !ok {
+		return []error{errors.Errorf("type '%s' is not defined in the JSON-LD context '%s'",
+			typeName, contextURL)}, nil
+	}
+	fields, ok := termContext(typeDefinition)
+	if !ok {
+		return nil, []error{errors.Errorf("type '%s' has no scoped context, fields are not checked", typeName)}
+	}
+
+	for _, target := range targets {
+		scope := fields
+		for _, name := range strings.Split(target, ".") {
+			definition, ok := scope[name]
+			if !ok {
+				issues = append(issues, errors.Errorf("field 'credentialSubject.%s' is not defined in the type '%s'",
+					target, typeName))
+				break
+			}
+			// fields of nested objects are checked if the object has a scoped context
+			if scope, ok = termContext(definition); !ok {
+				break
+			}
+		}
+	}
+	return issues, nil
+}
+
+// findTerm looks up the term by name or by '@id' in the context,
+// which can be an object or a list of objects.
+func findTerm(ldContext interface{}, name, id string) (interface{}, bool) {
+	switch c := ldContext.(type) {
+	case []interface{}:
+		for _, item := range c {
+			if term, ok := findTerm(item, name, id); ok {
+				return term, true
+			}
+		}
+	case map[string]interface{}:
+		if term, ok := c[name]; ok {
+			return term, true
+		}
+		for _, term := range c {
+			if definition, ok := term.(map[string]interface{}); ok && definition["@id"] == id {
+				return term, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// termContext returns the scoped context of the term definition.
+func termContext(definition interface{}) (map[string]interface{}, bool) {
+	d, ok := definition.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	c, ok := d["@context"].(map[string]interface{})
+	return c, ok
+}
@@ -0,0 +1,206 @@
+// Package lint validates the data provider configuration file before
+// the providers are created, and reports all found issues at once.
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/0xPolygonID/refresh-service/providers/chain"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/0xPolygonID/refresh-service/providers/onchain"
+	"github.com/piprate/json-gold/ld"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Severities of issues.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+var yamlLinePrefix = regexp.MustCompile(`^line \d+: `)
+
+// Issue is a problem of a configuration entry.
+type Issue struct {
+	CredentialType string
+	// Line is the line of the entry in the configuration file.
+	Line     int
+	Severity string
+	Message  string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("line %d: %s: '%s': %s", i.Line, i.Severity, i.CredentialType, i.Message)
+}
+
+// Report is the result of the configuration validation.
+type Report struct {
+	Path   string
+	Issues []Issue
+}
+
+func (r *Report) count(severity string) int {
+	n := 0
+	for _, i := range r.Issues {
+		if i.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// HasErrors reports whether the configuration has issues with the error severity.
+func (r *Report) HasErrors() bool {
+	return r.count(SeverityError) > 0
+}
+
+// String formats the report with one issue per line.
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d errors, %d warnings", r.Path, r.count(SeverityError), r.count(SeverityWarning))
+	for _, i := range r.Issues {
+		b.WriteString("\n  ")
+		b.WriteString(i.String())
+	}
+	return b.String()
+}
+
+type linter struct {
+	documentLoader ld.DocumentLoader
+}
+
+// Option configures the validation.
+type Option func(*linter)
+
+// WithDocumentLoader enables the check that the mapped fields are defined in
+// the JSON-LD context of the credential type. The context is resolved with the loader.
+func WithDocumentLoader(loader ld.DocumentLoader) Option {
+	return func(l *linter) {
+		l.documentLoader = loader
+	}
+}
+
+type entryHeader struct {
+	Provider struct {
+		Type string `yaml:"type"`
+	} `yaml:"provider"`
+}
+
+// Lint validates every entry of the configuration file. An error is returned
+// only if the file can't be read or isn't a YAML map, other problems are in the report.
+func Lint(configPath string, opts ...Option) (*Report, error) {
+	//nolint:gosec // configPath is a constant path in the project
+	f, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return LintBytes(configPath, f, opts...)
+}
+
+// LintBytes is like Lint, but takes the content of the configuration file.
+// configPath is only used in the report.
+func LintBytes(configPath string, f []byte, opts ...Option) (*Report, error) {
+	l := &linter{}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	var entries map[string]yaml.Node
+	if err := yaml.Unmarshal(f, &entries); err != nil {
+		return nil, errors.Errorf("invalid configuration file: %v", err)
+	}
+
+	report := &Report{Path: configPath}
+	defined := func(credentialType string) bool {
+		_, ok := entries[credentialType]
+		return ok
+	}
+	credentialTypes := make([]string, 0, len(entries))
+	for credentialType := range entries {
+		credentialTypes = append(credentialTypes, credentialType)
+	}
+	sort.Strings(credentialTypes)
+
+	for _, credentialType := range credentialTypes {
+		node := entries[credentialType]
+		add := func(severity string, errs ...error) {
+			for _, err := range errs {
+				report.Issues = append(report.Issues, Issue{
+					CredentialType: credentialType,
+					Line:           node.Line,
+					Severity:       severity,
+					Message:        err.Error(),
+				})
+			}
+		}
+
+		var header entryHeader
+		if err := node.Decode(&header); err != nil {
+			add(SeverityError, err)
+			continue
+		}
+
+		var schema *flexiblehttp.ResponseSchema
+		switch header.Provider.Type {
+		case "", flexiblehttp.ProviderType, flexiblehttp.ProviderTypeGraphQL:
+			var fh flexiblehttp.FlexibleHTTP
+			if errs := decode(&node, &fh); len(errs) > 0 {
+				add(SeverityError, errs...)
+				continue
+			}
+			add(SeverityError, fh.Lint()...)
+			schema = &fh.ResponseSchema
+		case onchain.ProviderType:
+			var o onchain.Onchain
+			if errs := decode(&node, &o); len(errs) > 0 {
+				add(SeverityError, errs...)
+				continue
+			}
+			add(SeverityError, o.Lint()...)
+			schema = &o.ResponseSchema
+		case chain.ProviderType:
+			issues, warnings := chain.Lint(&node, defined)
+			add(SeverityError, issues...)
+			add(SeverityWarning, warnings...)
+		default:
+			add(SeverityError, errors.Errorf("unsupported provider type '%s'", header.Provider.Type))
+		}
+
+		if schema != nil && l.documentLoader != nil {
+			issues, warnings := l.lintContext(credentialType, schema.MatchTargets())
+			add(SeverityError, issues...)
+			add(SeverityWarning, warnings...)
+		}
+	}
+	return report, nil
+}
+
+// decode decodes the entry and reports unknown fields, which are usually typos.
+func decode(node *yaml.Node, v interface{}) []error {
+	b, err := yaml.Marshal(node)
+	if err != nil {
+		return []error{err}
+	}
+	d := yaml.NewDecoder(bytes.NewReader(b))
+	d.KnownFields(true)
+	err = d.Decode(v)
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		errs := make([]error, 0, len(typeErr.Errors))
+		for _, e := range typeErr.Errors {
+			// lines are relative to the entry, they are replaced by the line of the entry
+			errs = append(errs, errors.New(yamlLinePrefix.ReplaceAllString(e, "")))
+		}
+		return errs
+	}
+	if err != nil {
+		return []error{err}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/piprate/json-gold/ld"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type staticLoader map[string]interface{}
+
+func (s staticLoader) LoadDocument(u string) (*ld.RemoteDocument, error) {
+	d, ok := s[u]
+	if !ok {
+		return nil, errors.Errorf("document '%s' not found", u)
+	}
+	return &ld.RemoteDocument{DocumentURL: u, Document: d}, nil
+}
+
+func issuesOf(report *Report, credentialType, severity string) []string {
+	var messages []string
+	for _, i := range report.Issues {
+		if i.CredentialType == credentialType && i.Severity == severity {
+			messages = append(messages, i.Message)
+		}
+	}
+	return messages
+}
+
+func requireContains(t *testing.T, messages []string, substrings ...string) {
+	t.Helper()
+	require.Len(t, messages, len(substrings), messages)
+	for i, s := range substrings {
+		require.Contains(t, messages[i], s)
+	}
+}
+
+func TestLint(t *testing.T) {
+	report, err := Lint("./testvectors/config.yaml")
+	require.NoError(t, err)
+	require.True(t, report.HasErrors())
+
+	tests := []struct {
+		credentialType string
+		errors         []string
+		warnings       []string
+	}{
+		{
+			credentialType: "https://example.com/balance.jsonld#Balance",
+		},
+		{
+			credentialType: "urn:test:Invalid",
+			errors:         []string{"field respnseSchema not found"},
+		},
+		{
+			credentialType: "urn:test:InvalidResponse",
+			errors: []string{
+				"property '[': invalid JMESPath expression",
+				"properties '[' and 'owner' are matched to conflicting fields",
+				"property 'result': unsupported type 'int'",
+				"property 'result': match 'balance' should start with 'credentialSubject.'",
+			},
+		},
+		{
+			credentialType: "urn:test:Onchain",
+			errors:         []string{"argument 0: invalid template"},
+		},
+		{
+			credentialType: "urn:test:Chain",
+			errors:         []string{"unsupported chain mode 'median'"},
+			warnings:       []string{"provider 'urn:test:Custom' is not defined"},
+		},
+		{
+			credentialType: "urn:test:ServiceSecret",
+			errors:         []string{"invalid auth: token: environment variable 'ISSUERS_BASIC_AUTH' is not allowed"},
+		},
+		{
+			credentialType: "urn:test:Unknown",
+			errors:         []string{"unsupported provider type 'soap'"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.credentialType, func(t *testing.T) {
+			requireContains(t, issuesOf(report, tt.credentialType, SeverityError), tt.errors...)
+			requireContains(t, issuesOf(report, tt.credentialType, SeverityWarning), tt.warnings...)
+		})
+	}
+	require.Contains(t, report.String(), "./testvectors/config.yaml: 9 errors, 1 warnings")
+}
+
+func TestLint_Template(t *testing.T) {
+	report, err := Lint("./testvectors/template.yaml")
+	require.NoError(t, err)
+	requireContains(t, issuesOf(report, "urn:test:Template", SeverityError),
+		"param 'address': placeholder '.address' should start with")
+}
+
+func TestLint_Context(t *testing.T) {
+	loader := staticLoader{
+		"https://example.com/balance.jsonld": map[string]interface{}{
+			"@context": []interface{}{
+				map[string]interface{}{
+					"@version": 1.1,
+					"Balance": map[string]interface{}{
+						"@id": "https://example.com/balance.jsonld#Balance",
+						"@context": map[string]interface{}{
+							"balance": map[string]interface{}{"@id": "urn:balance"},
+							"owner": map[string]interface{}{
+								"@id":      "urn:owner",
+								"@context": map[string]interface{}{"address": "urn:address"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	report, err := Lint("./testvectors/config.yaml", WithDocumentLoader(loader))
+	require.NoError(t, err)
+	requireContains(t, issuesOf(report, "https://example.com/balance.jsonld#Balance", SeverityError),
+		"field 'credentialSubject.owner.name' is not defined in the type 'Balance'")
+	requireContains(t, issuesOf(report, "urn:test:InvalidResponse", SeverityWarning),
+		"the JSON-LD context is not checked")
+}
+
+func TestLint_Error(t *testing.T) {
+	_, err := Lint("./testvectors/not-found.yaml")
+	require.Error(t, err)
+}
@@ -0,0 +1,219 @@
+package onchain
+
+import (
+	"math/big"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+var signatureRegexp = regexp.MustCompile(`^\s*(\w+)\s*\(([^()]*)\)\s*(?:returns\s*)?(?:\(([^()]*)\))?\s*$`)
+
+// knownMethods are signatures of common token methods that can be used by name.
+var knownMethods = map[string]string{
+	"balanceOf":   "balanceOf(address account)(uint256 balance)",
+	"ownerOf":     "ownerOf(uint256 tokenId)(address owner)",
+	"totalSupply": "totalSupply()(uint256 totalSupply)",
+	"decimals":    "decimals()(uint8 decimals)",
+}
+
+// parseSignature parses a human-readable method signature like
+// 'balanceOf(address)(uint256 balance)'. Tuples are not supported,
+// use the JSON ABI for methods with tuples.
+func parseSignature(signature string) (abi.Method, error) {
+	if known, ok := knownMethods[strings.TrimSpace(signature)]; ok {
+		signature = known
+	}
+	m := signatureRegexp.FindStringSubmatch(signature)
+	if m == nil {
+		return abi.Method{}, errors.Errorf("invalid method signature '%s'", signature)
+	}
+	inputs, err := parseArguments(m[2])
+	if err != nil {
+		return abi.Method{}, errors.Errorf("invalid inputs of '%s': %v", signature, err)
+	}
+	outputs, err := parseArguments(m[3])
+	if err != nil {
+		return abi.Method{}, errors.Errorf("invalid outputs of '%s': %v", signature, err)
+	}
+	return abi.NewMethod(m[1], m[1], abi.Function, "view", true, false, inputs, outputs), nil
+}
+
+func parseArguments(list string) (abi.Arguments, error) {
+	list = strings.TrimSpace(list)
+	if list == "" {
+		return abi.Arguments{}, nil
+	}
+	params := strings.Split(list, ",")
+	arguments := make(abi.Arguments, 0, len(params))
+	for _, param := range params {
+		fields := strings.Fields(param)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, errors.Errorf("invalid argument '%s'", param)
+		}
+		t, err := abi.NewType(fields[0], "", nil)
+		if err != nil {
+			return nil, err
+		}
+		argument := abi.Argument{Type: t}
+		if len(fields) == 2 {
+			argument.Name = fields[1]
+		}
+		arguments = append(arguments, argument)
+	}
+	return arguments, nil
+}
+
+// toABIValue converts a value from a template to the Go type expected by the ABI encoder.
+func toABIValue(t abi.Type, v interface{}) (interface{}, error) {
+	s, isString := v.(string)
+	switch t.T {
+	case abi.AddressTy:
+		if !isString || !common.IsHexAddress(s) {
+			return nil, errors.Errorf("'%v' is not an address", v)
+		}
+		return common.HexToAddress(s), nil
+	case abi.BoolTy:
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return v == "true", nil
+		default:
+			return nil, errors.Errorf("'%v' is not a boolean", v)
+		}
+	case abi.StringTy:
+		if !isString {
+			return nil, errors.Errorf("'%v' is not a string", v)
+		}
+		return s, nil
+	case abi.BytesTy:
+		if !isString {
+			return nil, errors.Errorf("'%v' is not a hex string", v)
+		}
+		return hexutil.Decode(s)
+	case abi.FixedBytesTy:
+		if !isString {
+			return nil, errors.Errorf("'%v' is not a hex string", v)
+		}
+		b, err := hexutil.Decode(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != t.Size {
+			return nil, errors.Errorf("expected %d bytes, got %d", t.Size, len(b))
+		}
+		array := reflect.New(t.GetType()).Elem()
+		reflect.Copy(array, reflect.ValueOf(b))
+		return array.Interface(), nil
+	case abi.IntTy, abi.UintTy:
+		n, err := toBigInt(v)
+		if err != nil {
+			return nil, err
+		}
+		goType := t.GetType()
+		if goType == reflect.TypeOf(&big.Int{}) {
+			return n, nil
+		}
+		value := reflect.New(goType).Elem()
+		if t.T == abi.UintTy {
+			if n.Sign() < 0 || !n.IsUint64() || value.OverflowUint(n.Uint64()) {
+				return nil, errors.Errorf("'%s' overflows %s", n, t)
+			}
+			value.SetUint(n.Uint64())
+		} else {
+			if !n.IsInt64() || value.OverflowInt(n.Int64()) {
+				return nil, errors.Errorf("'%s' overflows %s", n, t)
+			}
+			value.SetInt(n.Int64())
+		}
+		return value.Interface(), nil
+	default:
+		return nil, errors.Errorf("unsupported argument type '%s'", t)
+	}
+}
+
+func toBigInt(v interface{}) (*big.Int, error) {
+	switch v := v.(type) {
+	case string:
+		n, ok := new(big.Int).SetString(v, 0)
+		if !ok {
+			return nil, errors.Errorf("'%s' is not an integer", v)
+		}
+		return n, nil
+	case float64:
+		n, accuracy := big.NewFloat(v).Int(nil)
+		if accuracy != big.Exact {
+			return nil, errors.Errorf("'%v' is not an integer", v)
+		}
+		return n, nil
+	case int:
+		return big.NewInt(int64(v)), nil
+	default:
+		return nil, errors.Errorf("'%v' of type '%T' is not an integer", v, v)
+	}
+}
+
+// fromABIValue converts a decoded ABI value to a JSON-like value.
+// Integers are converted to decimal strings to keep precision,
+// addresses and bytes are converted to hex strings.
+func fromABIValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	case bool, string:
+		return v
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()).String()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(rv.Uint()).String()
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return hexutil.Encode(b)
+		}
+		return fromABIList(rv)
+	case reflect.Slice:
+		return fromABIList(rv)
+	case reflect.Struct:
+		fields := make(map[string]interface{}, rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			fields[lowerFirst(rv.Type().Field(i).Name)] = fromABIValue(rv.Field(i).Interface())
+		}
+		return fields
+	default:
+		return v
+	}
+}
+
+func fromABIList(rv reflect.Value) []interface{} {
+	list := make([]interface{}, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		list = append(list, fromABIValue(rv.Index(i).Interface()))
+	}
+	return list
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
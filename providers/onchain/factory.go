@@ -0,0 +1,76 @@
+package onchain
+
+import (
+	"os"
+
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/ethereum/go-ethereum"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+type FactoryOnchain struct {
+	configuration map[string]Onchain
+	callers       map[int]ethereum.ContractCaller
+}
+
+// NewFactoryOnchain reads entries with the 'onchain' provider type from the configuration file.
+// callers are contract callers by chain ID.
+func NewFactoryOnchain(configPath string, callers map[int]ethereum.ContractCaller) (FactoryOnchain, error) {
+	//nolint:gosec // configPath is a constant path in the project
+	f, err := os.ReadFile(configPath)
+	if err != nil {
+		return FactoryOnchain{}, err
+	}
+	return NewFactoryOnchainFromBytes(f, callers)
+}
+
+// NewFactoryOnchainFromBytes is like NewFactoryOnchain, but takes the content of the configuration file.
+func NewFactoryOnchainFromBytes(f []byte, callers map[int]ethereum.ContractCaller) (FactoryOnchain, error) {
+	cfgs := make(map[string]Onchain)
+	if err := yaml.Unmarshal(f, &cfgs); err != nil {
+		return FactoryOnchain{}, err
+	}
+	for credentialType, cfg := range cfgs {
+		if cfg.Provider.Type != ProviderType {
+			delete(cfgs, credentialType)
+		}
+	}
+	return FactoryOnchain{
+		configuration: cfgs,
+		callers:       callers,
+	}, nil
+}
+
+func (factory *FactoryOnchain) ProduceOnchain(credentialType string) (Onchain, error) {
+	o, ok := factory.configuration[credentialType]
+	if !ok {
+		return Onchain{}, errors.Errorf("not found configuration for '%s'", credentialType)
+	}
+	caller, ok := factory.callers[o.Provider.ChainID]
+	if !ok {
+		return Onchain{}, errors.Errorf("not supported RPC for chain '%d' of '%s'",
+			o.Provider.ChainID, credentialType)
+	}
+	method, err := o.Provider.method()
+	if err != nil {
+		return Onchain{}, errors.Errorf("invalid method for '%s': %v", credentialType, err)
+	}
+	o.caller = caller
+	o.method = method
+	return o, nil
+}
+
+// Register adds all configured providers to the registry.
+func (factory *FactoryOnchain) Register(registry *providers.Registry) error {
+	for credentialType := range factory.configuration {
+		o, err := factory.ProduceOnchain(credentialType)
+		if err != nil {
+			return err
+		}
+		if err := registry.Register(credentialType, &o, o.Settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
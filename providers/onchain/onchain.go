@@ -0,0 +1,175 @@
+package onchain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// ProviderType is the value of provider.type for on-chain data providers.
+const ProviderType = "onchain"
+
+type provider struct {
+	Type     string   `yaml:"type"`
+	ChainID  int      `yaml:"chainID"`
+	Contract string   `yaml:"contract"`
+	Method   string   `yaml:"method"`
+	ABI      string   `yaml:"abi"`
+	Args     []string `yaml:"args"`
+}
+
+// method returns the contract method from the JSON ABI, or parses
+// the method signature if the ABI is not defined.
+func (p provider) method() (abi.Method, error) {
+	if p.ABI == "" {
+		return parseSignature(p.Method)
+	}
+	contractABI, err := abi.JSON(strings.NewReader(p.ABI))
+	if err != nil {
+		return abi.Method{}, errors.Errorf("invalid abi: %v", err)
+	}
+	m, ok := contractABI.Methods[p.Method]
+	if !ok {
+		return abi.Method{}, errors.Errorf("method '%s' not found in abi", p.Method)
+	}
+	return m, nil
+}
+
+// Onchain is a data provider that reads credential fields from a smart contract with eth_call.
+type Onchain struct {
+	caller         ethereum.ContractCaller
+	method         abi.Method
+	Settings       providers.Settings          `yaml:"settings"`
+	Provider       provider                    `yaml:"provider"`
+	ResponseSchema flexiblehttp.ResponseSchema `yaml:"responseSchema"`
+}
+
+// String describes the provider by its chain, contract and method.
+func (o *Onchain) String() string {
+	return fmt.Sprintf("%s %d %s %s", ProviderType, o.Provider.ChainID, o.Provider.Contract, o.Provider.Method)
+}
+
+// Provide implements providers.Provider.
+func (o *Onchain) Provide(ctx context.Context, credential *verifiable.W3CCredential) (map[string]interface{}, error) {
+	msg, err := o.BuildCall(credential)
+	if err != nil {
+		return nil, errors.Wrap(flexiblehttp.ErrInvalidRequestSchema, err.Error())
+	}
+
+	output, err := o.caller.CallContract(ctx, msg, nil)
+	if err != nil {
+		return nil, errors.Wrapf(flexiblehttp.ErrDataProviderIssue,
+			"failed eth_call to '%s': %v", msg.To, err)
+	}
+
+	response, err := o.DecodeOutput(output)
+	if err != nil {
+		return nil, errors.Wrapf(flexiblehttp.ErrDataProviderIssue,
+			"failed to decode output: %v", err)
+	}
+
+	fields, err := o.ResponseSchema.Map(response)
+	if err != nil {
+		return nil, errors.Wrapf(flexiblehttp.ErrInvalidResponseSchema,
+			"failed to decode response by response schema: %v", err)
+	}
+	return fields, nil
+}
+
+// BuildCall renders the contract address and the arguments and packs the call data.
+func (o *Onchain) BuildCall(credential *verifiable.W3CCredential) (ethereum.CallMsg, error) {
+	did, _ := credential.CredentialSubject["id"].(string)
+	data, err := flexiblehttp.NewTemplateData(credential, did)
+	if err != nil {
+		return ethereum.CallMsg{}, err
+	}
+
+	contract, err := data.Render(o.Provider.Contract)
+	if err != nil {
+		return ethereum.CallMsg{}, err
+	}
+	if !common.IsHexAddress(contract) {
+		return ethereum.CallMsg{}, errors.Errorf("invalid contract address '%s'", contract)
+	}
+
+	if len(o.Provider.Args) != len(o.method.Inputs) {
+		return ethereum.CallMsg{}, errors.Errorf("method '%s' expects %d arguments, got %d",
+			o.method.Sig, len(o.method.Inputs), len(o.Provider.Args))
+	}
+	args := make([]interface{}, 0, len(o.Provider.Args))
+	for i, arg := range o.Provider.Args {
+		v, err := data.Value(arg)
+		if err != nil {
+			return ethereum.CallMsg{}, err
+		}
+		abiValue, err := toABIValue(o.method.Inputs[i].Type, v)
+		if err != nil {
+			return ethereum.CallMsg{}, errors.Errorf("argument %d of '%s': %v", i, o.method.Sig, err)
+		}
+		args = append(args, abiValue)
+	}
+	packed, err := o.method.Inputs.Pack(args...)
+	if err != nil {
+		return ethereum.CallMsg{}, errors.Errorf("failed to pack arguments: %v", err)
+	}
+
+	to := common.HexToAddress(contract)
+	return ethereum.CallMsg{
+		To:   &to,
+		Data: append(append([]byte{}, o.method.ID...), packed...),
+	}, nil
+}
+
+// DecodeOutput unpacks the call output to an object. Named outputs are
+// available by name, all outputs are available by position in 'outputs'.
+func (o *Onchain) DecodeOutput(output []byte) (map[string]interface{}, error) {
+	values, err := o.method.Outputs.Unpack(output)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]interface{}, 0, len(values))
+	response := make(map[string]interface{}, len(values)+1)
+	for i, v := range values {
+		value := fromABIValue(v)
+		outputs = append(outputs, value)
+		if name := o.method.Outputs[i].Name; name != "" {
+			response[name] = value
+		}
+	}
+	response["outputs"] = outputs
+	return response, nil
+}
+
+// Lint checks the provider configuration and returns all found issues.
+func (o *Onchain) Lint() []error {
+	var issues []error
+	if o.Provider.ChainID == 0 {
+		issues = append(issues, errors.New("provider chainID is not defined"))
+	}
+	if err := flexiblehttp.LintTemplate(o.Provider.Contract); err != nil {
+		issues = append(issues, errors.Errorf("contract: %v", err))
+	} else if !strings.Contains(o.Provider.Contract, "{{") && !common.IsHexAddress(o.Provider.Contract) {
+		issues = append(issues, errors.Errorf("invalid contract address '%s'", o.Provider.Contract))
+	}
+	method, err := o.Provider.method()
+	if err != nil {
+		issues = append(issues, errors.Errorf("invalid method: %v", err))
+	} else if len(o.Provider.Args) != len(method.Inputs) {
+		issues = append(issues, errors.Errorf("method '%s' expects %d arguments, got %d",
+			method.Sig, len(method.Inputs), len(o.Provider.Args)))
+	}
+	for i, arg := range o.Provider.Args {
+		if err := flexiblehttp.LintTemplate(arg); err != nil {
+			issues = append(issues, errors.Errorf("argument %d: %v", i, err))
+		}
+	}
+	return append(issues, o.ResponseSchema.Lint()...)
+}
@@ -0,0 +1,127 @@
+package onchain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	// addThousandContract returns the first argument plus 1000.
+	addThousandContract = hexutil.MustDecode("0x6004356103e80160005260206000f3")
+	// memberContract returns the first argument and true.
+	memberContract = hexutil.MustDecode("0x600435600052600160205260406000f3")
+)
+
+func newSimulatedCallers(t *testing.T) map[int]ethereum.ContractCaller {
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		common.HexToAddress("0xaa"): {Code: addThousandContract},
+		common.HexToAddress("0xbb"): {Code: memberContract},
+	})
+	t.Cleanup(func() {
+		_ = backend.Close()
+	})
+	return map[int]ethereum.ContractCaller{
+		1337: backend.Client(),
+	}
+}
+
+func TestProvide(t *testing.T) {
+	tests := []struct {
+		name              string
+		credentialType    string
+		credentialSubject map[string]interface{}
+		expected          map[string]interface{}
+	}{
+		{
+			name:           "Known ERC20 method",
+			credentialType: "urn:test:ERC20Balance",
+			credentialSubject: map[string]interface{}{
+				"address": "0x0000000000000000000000000000000000000005",
+			},
+			expected: map[string]interface{}{
+				"balance": "1005",
+			},
+		},
+		{
+			name:           "Method signature with templated contract",
+			credentialType: "urn:test:Signature",
+			credentialSubject: map[string]interface{}{
+				"contract": "0x00000000000000000000000000000000000000aa",
+				"stakeID":  float64(24),
+			},
+			expected: map[string]interface{}{
+				"stake": 1024,
+			},
+		},
+		{
+			name:           "JSON ABI with several outputs",
+			credentialType: "urn:test:ABI",
+			credentialSubject: map[string]interface{}{
+				"memberID": "7",
+			},
+			expected: map[string]interface{}{
+				"level":  7,
+				"active": true,
+			},
+		},
+	}
+
+	factory, err := NewFactoryOnchain("./testvectors/onchain.yaml", newSimulatedCallers(t))
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := factory.ProduceOnchain(tt.credentialType)
+			require.NoError(t, err)
+			fields, err := provider.Provide(context.Background(), &verifiable.W3CCredential{
+				CredentialSubject: tt.credentialSubject,
+			})
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, fields)
+		})
+	}
+}
+
+func TestProvide_Error(t *testing.T) {
+	factory, err := NewFactoryOnchain("./testvectors/onchain.yaml", newSimulatedCallers(t))
+	require.NoError(t, err)
+	provider, err := factory.ProduceOnchain("urn:test:ERC20Balance")
+	require.NoError(t, err)
+
+	_, err = provider.Provide(context.Background(), &verifiable.W3CCredential{
+		CredentialSubject: map[string]interface{}{
+			"address": "not an address",
+		},
+	})
+	require.ErrorIs(t, err, flexiblehttp.ErrInvalidRequestSchema)
+}
+
+func TestFactory(t *testing.T) {
+	factory, err := NewFactoryOnchain("./testvectors/onchain.yaml", newSimulatedCallers(t))
+	require.NoError(t, err)
+
+	_, err = factory.ProduceOnchain("urn:test:HTTP")
+	require.Error(t, err)
+
+	registry := providers.NewRegistry()
+	require.NoError(t, factory.Register(registry))
+	require.Equal(t, []string{"urn:test:ABI", "urn:test:ERC20Balance", "urn:test:Signature"}, registry.Types())
+
+	_, settings, err := registry.Get("urn:test:ERC20Balance")
+	require.NoError(t, err)
+	require.Equal(t, "5m0s", settings.TimeExpiration.String())
+
+	factory, err = NewFactoryOnchain("./testvectors/onchain.yaml", nil)
+	require.NoError(t, err)
+	_, err = factory.ProduceOnchain("urn:test:ERC20Balance")
+	require.Error(t, err)
+}
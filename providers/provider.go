@@ -0,0 +1,178 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrProviderNotFound      = errors.New("provider not found")
+	ErrProviderAlreadyExists = errors.New("provider already registered")
+)
+
+// Provider returns the fields of the credential subject that should be updated.
+type Provider interface {
+	Provide(ctx context.Context, credential *verifiable.W3CCredential) (map[string]interface{}, error)
+}
+
+// Settings are the refresh settings of a credential type.
+type Settings struct {
+	// TimeExpiration defines how long a credential must remain valid after a refresh.
+	TimeExpiration time.Duration `yaml:"timeExpiration"`
+	// Timeout limits the data provider call. It overrides the service-wide provider timeout.
+	Timeout time.Duration `yaml:"timeout"`
+	// Policy defines when a credential can be refreshed.
+	Policy RefreshPolicy `yaml:"policy"`
+}
+
+// RefreshPolicy defines when a credential can be refreshed.
+// By default only expired credentials are refreshed, without limits.
+type RefreshPolicy struct {
+	// RefreshWindow allows a refresh this long before the expiration.
+	RefreshWindow time.Duration `yaml:"refreshWindow"`
+	// MinInterval is the minimal time between the issuance of the credential and its refresh.
+	MinInterval time.Duration `yaml:"minInterval"`
+	// MaxRefreshes limits the number of refreshes of a credential. Zero means no limit.
+	// Refreshes are counted in the storage of the service, or in memory since its start if it has no storage.
+	MaxRefreshes int `yaml:"maxRefreshes"`
+	// AllowNotExpired allows a refresh at any time before the expiration.
+	AllowNotExpired bool `yaml:"allowNotExpired"`
+}
+
+type entry struct {
+	provider Provider
+	settings Settings
+}
+
+// Registry keeps data providers by credential type.
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]entry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		entries: make(map[string]entry),
+	}
+}
+
+// Register adds a provider for the credential type.
+func (r *Registry) Register(credentialType string, provider Provider, settings Settings) error {
+	if provider == nil {
+		return errors.Errorf("nil provider for '%s'", credentialType)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[credentialType]; ok {
+		return errors.Wrapf(ErrProviderAlreadyExists, "credential type '%s'", credentialType)
+	}
+	r.entries[credentialType] = entry{
+		provider: provider,
+		settings: settings,
+	}
+	return nil
+}
+
+// Get returns the provider and the settings for the credential type.
+func (r *Registry) Get(credentialType string) (Provider, Settings, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[credentialType]
+	if !ok {
+		return nil, Settings{}, errors.Wrapf(ErrProviderNotFound, "credential type '%s'", credentialType)
+	}
+	return e.provider, e.settings, nil
+}
+
+// Replace atomically replaces all providers with the providers of the other registry.
+func (r *Registry) Replace(other *Registry) {
+	other.mu.RLock()
+	entries := make(map[string]entry, len(other.entries))
+	for k, v := range other.entries {
+		entries[k] = v
+	}
+	other.mu.RUnlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = entries
+}
+
+// Types returns the sorted list of registered credential types.
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.entries))
+	for t := range r.entries {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Describe returns a short description of the provider for logs and the refresh history.
+// Providers can describe themselves by implementing fmt.Stringer.
+func Describe(p Provider) string {
+	if s, ok := p.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", p)
+}
+
+// CircuitBreaker is implemented by providers that fail fast while the data provider is down.
+type CircuitBreaker interface {
+	// CircuitBreakerState returns closed, open or half-open,
+	// or an empty string if the circuit breaker is not configured.
+	CircuitBreakerState() string
+}
+
+// CircuitBreakerState returns the state of the provider circuit breaker,
+// or an empty string if the provider has no circuit breaker.
+func CircuitBreakerState(p Provider) string {
+	if cb, ok := p.(CircuitBreaker); ok {
+		return cb.CircuitBreakerState()
+	}
+	return ""
+}
+
+// Static is a provider that always returns the same fields.
+type Static map[string]interface{}
+
+func (s Static) Provide(_ context.Context, _ *verifiable.W3CCredential) (map[string]interface{}, error) {
+	fields := make(map[string]interface{}, len(s))
+	for k, v := range s {
+		fields[k] = v
+	}
+	return fields, nil
+}
+
+// Composite is a provider that queries all providers in order and merges their fields.
+// If several providers return the same field, the value of the last one is used.
+type Composite []Provider
+
+func (c Composite) Provide(ctx context.Context, credential *verifiable.W3CCredential) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	for _, p := range c {
+		f, err := p.Provide(ctx, credential)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range f {
+			fields[k] = v
+		}
+	}
+	return fields, nil
+}
+
+// ProviderFunc is an adapter to use ordinary functions as providers.
+type ProviderFunc func(ctx context.Context, credential *verifiable.W3CCredential) (map[string]interface{}, error)
+
+func (f ProviderFunc) Provide(ctx context.Context, credential *verifiable.W3CCredential) (map[string]interface{}, error) {
+	return f(ctx, credential)
+}
@@ -0,0 +1,65 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	err := registry.Register("urn:test:Balance", Static{"balance": 100}, Settings{TimeExpiration: time.Minute})
+	require.NoError(t, err)
+
+	err = registry.Register("urn:test:Balance", Static{}, Settings{})
+	require.ErrorIs(t, err, ErrProviderAlreadyExists)
+
+	_, _, err = registry.Get("urn:test:Unknown")
+	require.ErrorIs(t, err, ErrProviderNotFound)
+
+	provider, settings, err := registry.Get("urn:test:Balance")
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, settings.TimeExpiration)
+	fields, err := provider.Provide(context.Background(), &verifiable.W3CCredential{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"balance": 100}, fields)
+
+	require.Equal(t, []string{"urn:test:Balance"}, registry.Types())
+}
+
+func TestComposite(t *testing.T) {
+	credential := &verifiable.W3CCredential{
+		CredentialSubject: map[string]interface{}{
+			"address": "0x6ae7E07c8763C284B7C91371f934E46c766D0ec6",
+		},
+	}
+	composite := Composite{
+		Static{"balance": 1, "currency": "MATIC"},
+		ProviderFunc(func(_ context.Context, credential *verifiable.W3CCredential) (map[string]interface{}, error) {
+			return map[string]interface{}{
+				"balance": 2,
+				"owner":   credential.CredentialSubject["address"],
+			}, nil
+		}),
+	}
+	fields, err := composite.Provide(context.Background(), credential)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"balance":  2,
+		"currency": "MATIC",
+		"owner":    "0x6ae7E07c8763C284B7C91371f934E46c766D0ec6",
+	}, fields)
+
+	errProvider := errors.New("provider is down")
+	composite = append(composite, ProviderFunc(
+		func(_ context.Context, _ *verifiable.W3CCredential) (map[string]interface{}, error) {
+			return nil, errProvider
+		}),
+	)
+	_, err = composite.Provide(context.Background(), credential)
+	require.ErrorIs(t, err, errProvider)
+}
@@ -0,0 +1,134 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/pkg/errors"
+)
+
+// ConfigVersion returns the version of the configuration:
+// the first 12 hex digits of the SHA-256 hash of its content.
+func ConfigVersion(config []byte) string {
+	h := sha256.Sum256(config)
+	return hex.EncodeToString(h[:6])
+}
+
+// Reloader replaces the providers of the registry when the configuration file changes.
+type Reloader struct {
+	path     string
+	registry *Registry
+	// load validates the content of the configuration file and creates providers from it.
+	load func(config []byte) (*Registry, error)
+
+	mu      sync.Mutex
+	version string
+}
+
+// NewReloader creates a reloader of the configuration file. load gets the content
+// of the file, it should validate the configuration and return a new registry
+// with all providers of the file. The file is read once per reload.
+func NewReloader(path string, registry *Registry, load func(config []byte) (*Registry, error)) *Reloader {
+	return &Reloader{
+		path:     path,
+		registry: registry,
+		load:     load,
+	}
+}
+
+// Version returns the version of the active configuration.
+func (r *Reloader) Version() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.version
+}
+
+// Reload loads the configuration file and atomically replaces the providers
+// of the registry. If the configuration is invalid, the active providers are kept.
+// The state of providers, like caches and circuit breakers, starts from scratch.
+func (r *Reloader) Reload() error {
+	config, err := r.read()
+	if err != nil {
+		return err
+	}
+	return r.reload(config)
+}
+
+// read reads the configuration file.
+func (r *Reloader) read() ([]byte, error) {
+	//nolint:gosec // the path is defined by the operator of the service
+	config, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, errors.Errorf("failed to read configuration: %v", err)
+	}
+	return config, nil
+}
+
+// reload loads the content of the configuration file.
+func (r *Reloader) reload(config []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	version := ConfigVersion(config)
+	registry, err := r.load(config)
+	if err != nil {
+		return errors.Errorf("configuration version '%s' is rejected: %v", version, err)
+	}
+	r.registry.Replace(registry)
+	r.version = version
+	logger.DefaultLogger.Infof("data provider configuration version '%s' is active: %d credential types",
+		version, len(registry.Types()))
+	return nil
+}
+
+// reloadIfChanged reloads the configuration if the version of the file differs
+// from the active one. A rejected version isn't loaded again until the file changes.
+func (r *Reloader) reloadIfChanged(rejected *string) {
+	config, err := r.read()
+	if err != nil {
+		logger.DefaultLogger.Errorf("failed to check data provider configuration: %v", err)
+		return
+	}
+	version := ConfigVersion(config)
+	if version == r.Version() || version == *rejected {
+		return
+	}
+	if err := r.reload(config); err != nil {
+		*rejected = version
+		logger.DefaultLogger.Errorf("failed to reload data provider configuration, version '%s' is kept: %v",
+			r.Version(), err)
+		return
+	}
+	*rejected = ""
+}
+
+// Watch reloads the configuration on every value from trigger, e.g. SIGHUP,
+// and when the file changes. The file is checked every interval,
+// a zero interval disables the check. Watch returns when the context is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration, trigger <-chan os.Signal) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	rejected := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-trigger:
+			if err := r.Reload(); err != nil {
+				logger.DefaultLogger.Errorf("failed to reload data provider configuration, version '%s' is kept: %v",
+					r.Version(), err)
+			}
+		case <-tick:
+			r.reloadIfChanged(&rejected)
+		}
+	}
+}
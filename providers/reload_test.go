@@ -0,0 +1,91 @@
+package providers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// loadTypes registers a static provider for every line of the configuration.
+// An empty line makes the configuration invalid.
+func loadTypes(config []byte) (*Registry, error) {
+	registry := NewRegistry()
+	for _, line := range strings.Split(strings.TrimSuffix(string(config), "\n"), "\n") {
+		if line == "" {
+			return nil, errors.New("empty credential type")
+		}
+		if err := registry.Register(line, Static{}, Settings{}); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+func TestReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("urn:test:A\n"), 0600))
+
+	registry := NewRegistry()
+	reloader := NewReloader(path, registry, loadTypes)
+	require.NoError(t, reloader.Reload())
+	require.Equal(t, []string{"urn:test:A"}, registry.Types())
+	version := reloader.Version()
+	require.Equal(t, ConfigVersion([]byte("urn:test:A\n")), version)
+	require.Len(t, version, 12)
+
+	require.NoError(t, os.WriteFile(path, []byte("urn:test:A\n\nurn:test:B\n"), 0600))
+	err := reloader.Reload()
+	require.ErrorContains(t, err, "empty credential type")
+	require.Equal(t, []string{"urn:test:A"}, registry.Types())
+	require.Equal(t, version, reloader.Version())
+
+	require.NoError(t, os.WriteFile(path, []byte("urn:test:A\nurn:test:B\n"), 0600))
+	require.NoError(t, reloader.Reload())
+	require.Equal(t, []string{"urn:test:A", "urn:test:B"}, registry.Types())
+	require.NotEqual(t, version, reloader.Version())
+}
+
+func TestReloader_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("urn:test:A\n"), 0600))
+
+	loads := make(chan struct{}, 10)
+	registry := NewRegistry()
+	reloader := NewReloader(path, registry, func(config []byte) (*Registry, error) {
+		loads <- struct{}{}
+		return loadTypes(config)
+	})
+	require.NoError(t, reloader.Reload())
+	<-loads
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	trigger := make(chan os.Signal, 1)
+	go reloader.Watch(ctx, 10*time.Millisecond, trigger)
+
+	// the file change is detected
+	require.NoError(t, os.WriteFile(path, []byte("urn:test:B\n"), 0600))
+	require.Eventually(t, func() bool {
+		types := registry.Types()
+		return len(types) == 1 && types[0] == "urn:test:B"
+	}, time.Second, 10*time.Millisecond)
+	<-loads
+
+	// an unchanged file isn't loaded again
+	time.Sleep(50 * time.Millisecond)
+	require.Empty(t, loads)
+
+	// the configuration is loaded on the trigger even if the file isn't changed
+	trigger <- os.Interrupt
+	select {
+	case <-loads:
+	case <-time.After(time.Second):
+		t.Fatal("configuration is not reloaded on the trigger")
+	}
+}
@@ -0,0 +1,418 @@
+package server
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrAdminUnauthorized   = errors.New("unauthorized")
+	ErrInvalidAdminRequest = errors.New("invalid admin request")
+	ErrHistoryDisabled     = errors.New("refresh history is disabled")
+	ErrReloadRejected      = errors.New("configuration is rejected")
+)
+
+//go:embed openapi.yaml
+var openAPIDocument []byte
+
+const maxAttemptsLimit = 1000
+
+// AdminConfig defines what the admin API manages.
+type AdminConfig struct {
+	// Token is the bearer token of the admin API. The admin API is disabled without the token.
+	Token string
+	// History is the storage of refresh attempts. The history endpoint fails if it is nil.
+	History   storage.Storage
+	Providers *providers.Registry
+	Reloader  *providers.Reloader
+	Refresh   *service.RefreshService
+	// Issuers are issuer node URLs by issuer DID, IssuersBasicAuth tells which issuers use basic auth.
+	Issuers          map[string]string
+	IssuersBasicAuth map[string]string
+	// RPC are RPC URLs by chain ID, StateContracts are state contract addresses by chain ID.
+	RPC            map[string]string
+	StateContracts map[string]string
+}
+
+// Admin serves the admin API.
+type Admin struct {
+	cfg AdminConfig
+}
+
+func NewAdmin(cfg AdminConfig) (*Admin, error) {
+	if cfg.Token == "" {
+		return nil, errors.New("admin token is not defined")
+	}
+	if cfg.Providers == nil || cfg.Reloader == nil || cfg.Refresh == nil {
+		return nil, errors.New("admin API requires providers, reloader and refresh service")
+	}
+	return &Admin{cfg: cfg}, nil
+}
+
+// Router returns the router of the admin API. The OpenAPI document is served without authentication.
+func (a *Admin) Router() http.Handler {
+	router := chi.NewRouter()
+	router.Get("/openapi.yaml", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write(openAPIDocument)
+	})
+	router.Group(func(r chi.Router) {
+		r.Use(a.authenticate)
+		r.Get("/attempts", a.listAttempts)
+		r.Get("/providers", a.listProviders)
+		r.Post("/providers/probe", a.probeProviders)
+		r.Post("/providers/reload", a.reloadProviders)
+		r.Get("/issuers", a.listIssuers)
+		r.Get("/chains", a.listChains)
+	})
+	return router
+}
+
+func (a *Admin) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.cfg.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			handleError(w, errors.Wrapf(ErrAdminUnauthorized, "%s %s", r.Method, r.URL.Path))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type attemptResponse struct {
+	ID                 int64     `json:"id"`
+	Issuer             string    `json:"issuer"`
+	ThreadID           string    `json:"threadID,omitempty"`
+	HolderDID          string    `json:"holderDID"`
+	CredentialID       string    `json:"credentialID"`
+	RefreshedID        string    `json:"refreshedID,omitempty"`
+	CredentialType     string    `json:"credentialType,omitempty"`
+	Provider           string    `json:"provider,omitempty"`
+	Outcome            string    `json:"outcome"`
+	ErrorCode          int       `json:"errorCode,omitempty"`
+	Error              string    `json:"error,omitempty"`
+	StartedAt          time.Time `json:"startedAt"`
+	DurationMs         int64     `json:"durationMs"`
+	ProviderDurationMs int64     `json:"providerDurationMs"`
+	IssuerDurationMs   int64     `json:"issuerDurationMs"`
+}
+
+func (a *Admin) listAttempts(w http.ResponseWriter, r *http.Request) {
+	if a.cfg.History == nil {
+		handleError(w, errors.Wrap(ErrHistoryDisabled, "STORAGE_URL is not defined"))
+		return
+	}
+	filter, err := parseFilter(r.URL.Query())
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	attempts, err := a.cfg.History.ListAttempts(r.Context(), filter)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	res := make([]attemptResponse, 0, len(attempts))
+	for _, attempt := range attempts {
+		res = append(res, attemptResponse{
+			ID:                 attempt.ID,
+			Issuer:             attempt.Issuer,
+			ThreadID:           attempt.ThreadID,
+			HolderDID:          attempt.HolderDID,
+			CredentialID:       attempt.CredentialID,
+			RefreshedID:        attempt.RefreshedID,
+			CredentialType:     attempt.CredentialType,
+			Provider:           attempt.Provider,
+			Outcome:            attempt.Outcome,
+			ErrorCode:          attempt.ErrorCode,
+			Error:              attempt.Error,
+			StartedAt:          attempt.StartedAt,
+			DurationMs:         attempt.Duration.Milliseconds(),
+			ProviderDurationMs: attempt.ProviderDuration.Milliseconds(),
+			IssuerDurationMs:   attempt.IssuerDuration.Milliseconds(),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"attempts": res})
+}
+
+func parseFilter(query url.Values) (storage.Filter, error) {
+	filter := storage.Filter{
+		Issuer:         query.Get("issuer"),
+		HolderDID:      query.Get("holderDID"),
+		CredentialID:   query.Get("credentialID"),
+		CredentialType: query.Get("credentialType"),
+		Outcome:        query.Get("outcome"),
+	}
+	switch filter.Outcome {
+	case "", storage.OutcomeSuccess, storage.OutcomeFailure, storage.OutcomeDeduplicated:
+	default:
+		return storage.Filter{}, errors.Wrapf(ErrInvalidAdminRequest, "unsupported outcome '%s'", filter.Outcome)
+	}
+
+	var err error
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := query.Get(name); v != "" {
+			if *t, err = time.Parse(time.RFC3339, v); err != nil {
+				return storage.Filter{}, errors.Wrapf(ErrInvalidAdminRequest, "%s is not RFC 3339 time: '%s'", name, v)
+			}
+		}
+	}
+	for name, n := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if v := query.Get(name); v != "" {
+			if *n, err = strconv.Atoi(v); err != nil || *n < 0 {
+				return storage.Filter{}, errors.Wrapf(ErrInvalidAdminRequest, "%s is not a non-negative number: '%s'", name, v)
+			}
+		}
+	}
+	if filter.Limit > maxAttemptsLimit {
+		return storage.Filter{}, errors.Wrapf(ErrInvalidAdminRequest, "limit is greater than %d", maxAttemptsLimit)
+	}
+	return filter, nil
+}
+
+type policyResponse struct {
+	RefreshWindow   string `json:"refreshWindow"`
+	MinInterval     string `json:"minInterval"`
+	MaxRefreshes    int    `json:"maxRefreshes"`
+	AllowNotExpired bool   `json:"allowNotExpired"`
+}
+
+type providerResponse struct {
+	CredentialType string         `json:"credentialType"`
+	Provider       string         `json:"provider"`
+	TimeExpiration string         `json:"timeExpiration"`
+	Timeout        string         `json:"timeout"`
+	Policy         policyResponse `json:"policy"`
+	// CircuitBreaker is the state of the circuit breaker, empty if it isn't configured.
+	CircuitBreaker string `json:"circuitBreaker,omitempty"`
+}
+
+func (a *Admin) listProviders(w http.ResponseWriter, _ *http.Request) {
+	res := []providerResponse{}
+	for _, credentialType := range a.cfg.Providers.Types() {
+		provider, settings, err := a.cfg.Providers.Get(credentialType)
+		if err != nil {
+			// the configuration was reloaded meanwhile
+			continue
+		}
+		res = append(res, providerResponse{
+			CredentialType: credentialType,
+			Provider:       providers.Describe(provider),
+			TimeExpiration: settings.TimeExpiration.String(),
+			Timeout:        settings.Timeout.String(),
+			Policy: policyResponse{
+				RefreshWindow:   settings.Policy.RefreshWindow.String(),
+				MinInterval:     settings.Policy.MinInterval.String(),
+				MaxRefreshes:    settings.Policy.MaxRefreshes,
+				AllowNotExpired: settings.Policy.AllowNotExpired,
+			},
+			CircuitBreaker: providers.CircuitBreakerState(provider),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"version":   a.cfg.Reloader.Version(),
+		"providers": res,
+	})
+}
+
+type probeRequest struct {
+	// CredentialType selects the provider, all providers are probed if it is empty.
+	CredentialType string                    `json:"credentialType"`
+	Credential     *verifiable.W3CCredential `json:"credential"`
+}
+
+type probeResponse struct {
+	CredentialType string   `json:"credentialType"`
+	Provider       string   `json:"provider"`
+	Healthy        bool     `json:"healthy"`
+	Fields         []string `json:"fields,omitempty"`
+	DurationMs     int64    `json:"durationMs"`
+	ErrorCode      int      `json:"errorCode,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+func (a *Admin) probeProviders(w http.ResponseWriter, r *http.Request) {
+	var request probeRequest
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1024*1024))
+	if err != nil {
+		handleError(w, errors.Wrapf(ErrInvalidAdminRequest, "failed to read request body: %v", err))
+		return
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &request); err != nil {
+			handleError(w, errors.Wrapf(ErrInvalidAdminRequest, "invalid probe request: %v", err))
+			return
+		}
+	}
+	if request.Credential != nil && request.CredentialType == "" {
+		handleError(w, errors.Wrap(ErrInvalidAdminRequest, "credential requires credentialType"))
+		return
+	}
+
+	credentialTypes := a.cfg.Providers.Types()
+	if request.CredentialType != "" {
+		credentialTypes = []string{request.CredentialType}
+	}
+	var (
+		wg      sync.WaitGroup
+		results = make([]probeResponse, len(credentialTypes))
+		errs    = make([]error, len(credentialTypes))
+	)
+	for i, credentialType := range credentialTypes {
+		wg.Add(1)
+		go func(i int, credentialType string) {
+			defer wg.Done()
+			res, err := a.cfg.Refresh.Probe(r.Context(), credentialType, request.Credential)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			results[i] = probeResponse{
+				CredentialType: res.CredentialType,
+				Provider:       res.Provider,
+				Healthy:        res.Err == nil,
+				Fields:         res.Fields,
+				DurationMs:     res.Duration.Milliseconds(),
+			}
+			if res.Err != nil {
+				results[i].ErrorCode = ErrorCode(res.Err)
+				results[i].Error = res.Err.Error()
+			}
+		}(i, credentialType)
+	}
+	wg.Wait()
+
+	healthy := true
+	res := make([]probeResponse, 0, len(results))
+	for i := range results {
+		if errs[i] != nil {
+			if request.CredentialType != "" {
+				handleError(w, errs[i])
+				return
+			}
+			// the configuration was reloaded meanwhile
+			continue
+		}
+		healthy = healthy && results[i].Healthy
+		res = append(res, results[i])
+	}
+	status := http.StatusOK
+	if !healthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"healthy": healthy,
+		"results": res,
+	})
+}
+
+func (a *Admin) reloadProviders(w http.ResponseWriter, _ *http.Request) {
+	if err := a.cfg.Reloader.Reload(); err != nil {
+		handleError(w, errors.Wrap(ErrReloadRejected, err.Error()))
+		return
+	}
+	logger.DefaultLogger.Info("data provider configuration is reloaded by the admin API")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"version":         a.cfg.Reloader.Version(),
+		"credentialTypes": a.cfg.Providers.Types(),
+	})
+}
+
+type issuerResponse struct {
+	DID       string `json:"did"`
+	URL       string `json:"url"`
+	BasicAuth bool   `json:"basicAuth"`
+}
+
+func (a *Admin) listIssuers(w http.ResponseWriter, _ *http.Request) {
+	res := make([]issuerResponse, 0, len(a.cfg.Issuers))
+	for _, did := range sortedKeys(a.cfg.Issuers) {
+		_, basicAuth := a.cfg.IssuersBasicAuth[did]
+		if !basicAuth {
+			_, basicAuth = a.cfg.IssuersBasicAuth["*"]
+		}
+		res = append(res, issuerResponse{
+			DID:       did,
+			URL:       redactURL(a.cfg.Issuers[did], false),
+			BasicAuth: basicAuth,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"issuers": res})
+}
+
+type chainResponse struct {
+	ChainID       string `json:"chainID"`
+	RPC           string `json:"rpc,omitempty"`
+	StateContract string `json:"stateContract,omitempty"`
+}
+
+func (a *Admin) listChains(w http.ResponseWriter, _ *http.Request) {
+	chainIDs := sortedKeys(a.cfg.RPC)
+	for _, chainID := range sortedKeys(a.cfg.StateContracts) {
+		if _, ok := a.cfg.RPC[chainID]; !ok {
+			chainIDs = append(chainIDs, chainID)
+		}
+	}
+	res := make([]chainResponse, 0, len(chainIDs))
+	for _, chainID := range chainIDs {
+		res = append(res, chainResponse{
+			ChainID: chainID,
+			// RPC URLs often contain API keys in the path or the query
+			RPC:           redactURL(a.cfg.RPC[chainID], true),
+			StateContract: a.cfg.StateContracts[chainID],
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"chains": res})
+}
+
+// redactURL removes the user info from the URL, and the path and the query if hostOnly is set.
+func redactURL(rawURL string, hostOnly bool) string {
+	if rawURL == "" {
+		return ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "<invalid url>"
+	}
+	u.User = nil
+	if hostOnly {
+		return u.Scheme + "://" + u.Host
+	}
+	return u.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.DefaultLogger.Errorf("failed to write response: %v", err)
+	}
+}
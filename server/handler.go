@@ -15,14 +15,30 @@ import (
 
 type Handlers struct {
 	agentService *service.AgentService
+	admin        *Admin
+}
+
+// Option configures the Handlers.
+type Option func(*Handlers)
+
+// WithAdmin serves the admin API under /admin.
+func WithAdmin(admin *Admin) Option {
+	return func(h *Handlers) {
+		h.admin = admin
+	}
 }
 
 func NewHandlers(
 	agentService *service.AgentService,
+	opts ...Option,
 ) *Handlers {
-	return &Handlers{
+	h := &Handlers{
 		agentService: agentService,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 func (h *Handlers) Run(host string) error {
@@ -70,6 +86,10 @@ func (h *Handlers) Run(host string) error {
 		_, _ = w.Write([]byte(`{"string": "I'm mock refresh service"}`))
 	})
 
+	if h.admin != nil {
+		router.Mount("/admin", h.admin.Router())
+	}
+
 	logger.DefaultLogger.Infof("Server starting on host '%s'", host)
 	httpServer := &http.Server{
 		Addr:              host,
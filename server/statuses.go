@@ -5,8 +5,10 @@ import (
 	"net/http"
 
 	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/providers"
 	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
 	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/0xPolygonID/refresh-service/storage"
 	"github.com/pkg/errors"
 )
 
@@ -15,12 +17,14 @@ type jsonError struct {
 	Err  string `json:"error"`
 }
 
-func handleError(w http.ResponseWriter, err error) {
-	var (
-		message  string
-		code     int
-		httpCode int
-	)
+// ErrorCode returns the code of the error that is sent to the client.
+func ErrorCode(err error) int {
+	code, _, _ := classifyError(err)
+	return code
+}
+
+// classifyError returns the code of the error, the HTTP status and a possible solution.
+func classifyError(err error) (code, httpCode int, message string) {
 	switch {
 	case errors.Is(err, flexiblehttp.ErrInvalidRequestSchema):
 		code = 1000
@@ -52,15 +56,71 @@ func handleError(w http.ResponseWriter, err error) {
 	case errors.Is(err, service.ErrCreateClaim):
 		code = 3002
 		httpCode = http.StatusInternalServerError
+	case errors.Is(err, service.ErrRevokeClaim):
+		code = 3003
+		httpCode = http.StatusInternalServerError
+		message = "check that the issuer node supports revocation, the refreshed credential is revoked"
+	case errors.Is(err, service.ErrRollbackClaim):
+		code = 3004
+		httpCode = http.StatusInternalServerError
+		message = "the refresh failed and the refreshed credential is not revoked, the issuer must revoke it manually"
 
 	case errors.Is(err, service.ErrCredentialNotUpdatable):
 		code = 4000
 		httpCode = http.StatusBadRequest
 		message = "check that the credential you are trying to update has refreshService and the updatable flag is true"
+	case errors.Is(err, service.ErrCredentialSchemaValidation):
+		code = 4001
+		httpCode = http.StatusInternalServerError
+		message = "check that the response schema in provider configuration file matches the credential schema"
+	case errors.Is(err, service.ErrRefreshTooEarly):
+		code = 4002
+		httpCode = http.StatusBadRequest
+		message = "the credential can't be refreshed yet, check refreshWindow and allowNotExpired of the refresh policy"
+	case errors.Is(err, service.ErrRefreshTooFrequent):
+		code = 4003
+		httpCode = http.StatusTooManyRequests
+		message = "the credential was refreshed recently, check minInterval of the refresh policy"
+	case errors.Is(err, service.ErrRefreshLimitExceeded):
+		code = 4004
+		httpCode = http.StatusForbidden
+		message = "the credential reached the refresh limit, check maxRefreshes of the refresh policy"
+
+	case errors.Is(err, ErrAdminUnauthorized):
+		code = 5000
+		httpCode = http.StatusUnauthorized
+		message = "check the bearer token of the admin API"
+	case errors.Is(err, ErrInvalidAdminRequest):
+		code = 5001
+		httpCode = http.StatusBadRequest
+	case errors.Is(err, ErrHistoryDisabled):
+		code = 5002
+		httpCode = http.StatusNotImplemented
+		message = "define STORAGE_URL to record refresh attempts"
+	case errors.Is(err, ErrReloadRejected):
+		code = 5003
+		httpCode = http.StatusUnprocessableEntity
+		message = "fix the data provider configuration, the active configuration is kept"
+	case errors.Is(err, providers.ErrProviderNotFound):
+		code = 5004
+		httpCode = http.StatusNotFound
+	case errors.Is(err, storage.ErrStorage):
+		code = 5005
+		httpCode = http.StatusInternalServerError
+		message = "check the storage to be available"
+	case errors.Is(err, service.ErrCredentialSchemaUnavailable):
+		code = 5006
+		httpCode = http.StatusServiceUnavailable
+		message = "check that the credential schema is available by credentialSchema.id and the IPFS gateway"
 	default:
 		code = 500
 		httpCode = http.StatusInternalServerError
 	}
+	return code, httpCode, message
+}
+
+func handleError(w http.ResponseWriter, err error) {
+	code, httpCode, message := classifyError(err)
 
 	logger.DefaultLogger.Error(err)
 	if message != "" {
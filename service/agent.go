@@ -48,11 +48,12 @@ func (as *AgentService) Process(ctx context.Context, envelop []byte) (
 			return nil, errors.Wrapf(ErrInvalidProtocolMessage, "failed to unmarshal body: %v", err)
 		}
 
-		refreshed, err := as.refreshService.Process(
+		refreshed, err := as.refreshService.ProcessIdempotent(
 			ctx,
 			message.To,
 			message.From,
 			convertID(bodyMessage.ID),
+			message.ThreadID,
 		)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+)
+
+const saveAttemptTimeout = 5 * time.Second
+
+// WithHistory records every refresh attempt in the storage, including duplicate
+// requests that don't issue a credential. errorCode maps the error of a failed
+// attempt to the code that is sent to the client.
+func WithHistory(store storage.Storage, errorCode func(error) int) Option {
+	return func(rs *RefreshService) {
+		rs.history = store
+		rs.errorCode = errorCode
+	}
+}
+
+type attemptKey struct{}
+
+// withAttempt puts the attempt to the context, so steps of the refresh can fill it in.
+func withAttempt(ctx context.Context, attempt *storage.Attempt) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// attemptFrom returns the attempt from the context. It is a throwaway attempt
+// if the refresh isn't recorded, so callers don't have to check it.
+func attemptFrom(ctx context.Context) *storage.Attempt {
+	if attempt, ok := ctx.Value(attemptKey{}).(*storage.Attempt); ok {
+		return attempt
+	}
+	return &storage.Attempt{}
+}
+
+// trackIssuer adds the time since the start to the duration of issuer node requests.
+func trackIssuer(ctx context.Context, start time.Time) {
+	attemptFrom(ctx).IssuerDuration += time.Since(start)
+}
+
+// refresh runs Process and records the attempt.
+func (rs *RefreshService) refresh(
+	ctx context.Context,
+	issuer, owner, id, threadID string,
+) (*verifiable.W3CCredential, error) {
+	if rs.history == nil {
+		return rs.Process(ctx, issuer, owner, id)
+	}
+
+	attempt := newAttempt(issuer, owner, id, threadID, time.Now())
+	rc, err := rs.Process(withAttempt(ctx, attempt), issuer, owner, id)
+	if err != nil {
+		attempt.Outcome = storage.OutcomeFailure
+	} else {
+		attempt.Outcome = storage.OutcomeSuccess
+	}
+	rs.saveAttempt(ctx, attempt, rc, err)
+	return rc, err
+}
+
+// recordDeduplicated records a duplicate request that got the result of another
+// refresh. The error is the error of that refresh.
+func (rs *RefreshService) recordDeduplicated(
+	ctx context.Context,
+	issuer, owner, id, threadID string,
+	start time.Time,
+	rc *verifiable.W3CCredential,
+	err error,
+) {
+	if rs.history == nil {
+		return
+	}
+	attempt := newAttempt(issuer, owner, id, threadID, start)
+	attempt.Outcome = storage.OutcomeDeduplicated
+	rs.saveAttempt(ctx, attempt, rc, err)
+}
+
+func newAttempt(issuer, owner, id, threadID string, start time.Time) *storage.Attempt {
+	return &storage.Attempt{
+		Issuer:       issuer,
+		ThreadID:     threadID,
+		HolderDID:    owner,
+		CredentialID: id,
+		StartedAt:    start,
+	}
+}
+
+// saveAttempt sets the result and the duration of the attempt and saves it.
+func (rs *RefreshService) saveAttempt(
+	ctx context.Context,
+	attempt *storage.Attempt,
+	rc *verifiable.W3CCredential,
+	err error,
+) {
+	attempt.Duration = time.Since(attempt.StartedAt)
+	if err != nil {
+		attempt.Error = err.Error()
+		if rs.errorCode != nil {
+			attempt.ErrorCode = rs.errorCode(err)
+		}
+	} else {
+		attempt.RefreshedID = rc.ID
+	}
+
+	// the attempt is saved even if the client went away
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveAttemptTimeout)
+	defer cancel()
+	if saveErr := rs.history.SaveAttempt(saveCtx, attempt); saveErr != nil {
+		logger.DefaultLogger.Errorf("failed to save refresh attempt of credential '%s': %v",
+			attempt.CredentialID, saveErr)
+	}
+}
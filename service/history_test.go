@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefresh_RecordsAttempt(t *testing.T) {
+	node := newIssuerNode()
+	srv := httptest.NewServer(node.handler())
+	defer srv.Close()
+
+	tests := []struct {
+		name          string
+		issuer        string
+		expectedError error
+		expectedCode  int
+	}{
+		{
+			name:          "Unsupported issuer",
+			issuer:        "did:iden3:unknown",
+			expectedError: ErrIssuerNotSupported,
+			expectedCode:  3000,
+		},
+		{
+			name:          "Not owner of the credential",
+			issuer:        testIssuer,
+			expectedError: ErrCredentialNotUpdatable,
+			expectedCode:  4000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history, err := storage.Open(context.Background(), "sqlite://"+t.TempDir()+"/history.db")
+			require.NoError(t, err)
+			defer history.Close()
+
+			errorCode := func(err error) int {
+				switch {
+				case errors.Is(err, ErrIssuerNotSupported):
+					return 3000
+				case errors.Is(err, ErrCredentialNotUpdatable):
+					return 4000
+				default:
+					return 500
+				}
+			}
+			rs := NewRefreshService(
+				NewIssuerService(map[string]string{testIssuer: srv.URL}, nil, srv.Client()),
+				nil, nil,
+				WithHistory(history, errorCode),
+			)
+			_, refreshErr := rs.refresh(context.Background(), tt.issuer, "did:iden3:holder", "old", "thread")
+			require.ErrorIs(t, refreshErr, tt.expectedError)
+
+			attempts, err := history.ListAttempts(context.Background(), storage.Filter{})
+			require.NoError(t, err)
+			require.Len(t, attempts, 1)
+			attempt := attempts[0]
+			require.Equal(t, tt.issuer, attempt.Issuer)
+			require.Equal(t, "did:iden3:holder", attempt.HolderDID)
+			require.Equal(t, "old", attempt.CredentialID)
+			require.Equal(t, "thread", attempt.ThreadID)
+			require.Equal(t, storage.OutcomeFailure, attempt.Outcome)
+			require.Equal(t, tt.expectedCode, attempt.ErrorCode)
+			require.Equal(t, refreshErr.Error(), attempt.Error)
+			require.Empty(t, attempt.RefreshedID)
+		})
+	}
+}
+
+func TestProcessIdempotent_RecordsDeduplicated(t *testing.T) {
+	history, err := storage.Open(context.Background(), "sqlite://"+t.TempDir()+"/history.db")
+	require.NoError(t, err)
+	defer history.Close()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	rs := NewRefreshService(nil, nil, nil,
+		WithResultStore(nil, time.Minute),
+		WithHistory(history, nil),
+	)
+	// the refresh itself is recorded by refresh, which is replaced here
+	rs.process = func(_ context.Context, _, _, id, _ string) (*verifiable.W3CCredential, error) {
+		calls.Add(1)
+		<-release
+		return &verifiable.W3CCredential{ID: "new-" + id}, nil
+	}
+
+	// two requests wait for the refresh in flight
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := rs.ProcessIdempotent(context.Background(), testIssuer, "did:iden3:holder", "old", "thread")
+			require.NoError(t, err)
+		}()
+	}
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	// wait for other requests to join the refresh in flight
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// a repeated request gets the stored result
+	_, err = rs.ProcessIdempotent(context.Background(), testIssuer, "did:iden3:holder", "old", "thread")
+	require.NoError(t, err)
+	require.Equal(t, int32(1), calls.Load())
+
+	attempts, err := history.ListAttempts(context.Background(), storage.Filter{})
+	require.NoError(t, err)
+	require.Len(t, attempts, 3)
+	for _, attempt := range attempts {
+		require.Equal(t, storage.OutcomeDeduplicated, attempt.Outcome)
+		require.Equal(t, "new-old", attempt.RefreshedID)
+		require.Equal(t, "old", attempt.CredentialID)
+		require.Equal(t, "thread", attempt.ThreadID)
+	}
+}
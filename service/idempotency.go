@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/providers/cache"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+)
+
+const defaultResultStoreSize = 1000
+
+// WithResultStore keeps refreshed credentials for the ttl, so a repeated request
+// returns the same credential instead of issuing another one. A nil store means
+// an in-memory store. By default results are not kept, only concurrent
+// duplicates are coalesced.
+func WithResultStore(store cache.Cache, ttl time.Duration) Option {
+	return func(rs *RefreshService) {
+		if store == nil {
+			store = cache.NewMemory(defaultResultStoreSize)
+		}
+		rs.results = store
+		rs.resultTTL = ttl
+	}
+}
+
+// idempotencyKey identifies a refresh request. The owner is a part of the key,
+// so a result is never returned to another holder.
+func idempotencyKey(issuer, owner, id, threadID string) string {
+	return fmt.Sprintf("%s|%s|%s|%s", issuer, owner, id, threadID)
+}
+
+// ProcessIdempotent refreshes the credential like Process, but duplicate requests
+// with the same issuer, credential ID and thread ID don't issue another credential.
+// Concurrent duplicates wait for the refresh in flight, repeated requests get
+// the stored result. Failed refreshes are not stored. With the history, duplicates
+// are recorded with the deduplicated outcome; a caller that goes away while
+// waiting for the refresh in flight isn't recorded.
+func (rs *RefreshService) ProcessIdempotent(
+	ctx context.Context,
+	issuer, owner, id, threadID string,
+) (*verifiable.W3CCredential, error) {
+	start := time.Now()
+	key := idempotencyKey(issuer, owner, id, threadID)
+	if rc, ok := rs.storedResult(key); ok {
+		logger.DefaultLogger.Infof("return stored refresh result for credential '%s', thread '%s'", id, threadID)
+		rs.recordDeduplicated(ctx, issuer, owner, id, threadID, start, rc, nil)
+		return rc, nil
+	}
+
+	// refreshed is set if the function of this caller runs the refresh;
+	// it is written before the result is sent to the channel
+	refreshed := false
+	// the refresh in flight isn't canceled when the first caller goes away,
+	// other callers may wait for it; it is still limited by the refresh timeout
+	ch := rs.inflight.DoChan(key, func() (interface{}, error) {
+		if rc, ok := rs.storedResult(key); ok {
+			return rc, nil
+		}
+		refreshed = true
+		rc, err := rs.process(context.WithoutCancel(ctx), issuer, owner, id, threadID)
+		if err != nil {
+			return nil, err
+		}
+		if rs.results != nil && rs.resultTTL > 0 {
+			rs.results.Set(key, rc, rs.resultTTL)
+		}
+		return rc, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		var rc *verifiable.W3CCredential
+		if res.Err == nil {
+			rc = res.Val.(*verifiable.W3CCredential)
+		}
+		if !refreshed {
+			logger.DefaultLogger.Infof("refresh of credential '%s', thread '%s' is coalesced", id, threadID)
+			rs.recordDeduplicated(ctx, issuer, owner, id, threadID, start, rc, res.Err)
+		}
+		return rc, res.Err
+	}
+}
+
+func (rs *RefreshService) storedResult(key string) (*verifiable.W3CCredential, bool) {
+	if rs.results == nil {
+		return nil, false
+	}
+	v, ok := rs.results.Get(key)
+	if !ok {
+		return nil, false
+	}
+	rc, ok := v.(*verifiable.W3CCredential)
+	return rc, ok
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	rs := NewRefreshService(nil, nil, nil, WithResultStore(nil, time.Minute))
+	rs.process = func(_ context.Context, _, _, id, _ string) (*verifiable.W3CCredential, error) {
+		n := calls.Add(1)
+		<-release
+		if id == "failing" {
+			return nil, errors.New("refresh failed")
+		}
+		return &verifiable.W3CCredential{ID: fmt.Sprintf("%s-%d", id, n)}, nil
+	}
+
+	// concurrent duplicates coalesce into one refresh
+	var wg sync.WaitGroup
+	results := make([]*verifiable.W3CCredential, 5)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = rs.ProcessIdempotent(context.Background(), "issuer", "owner", "cred", "thread-1")
+		}(i)
+	}
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	for i, rc := range results {
+		require.NoError(t, errs[i])
+		require.Same(t, results[0], rc)
+	}
+
+	// a repeated request gets the stored result
+	rc, err := rs.ProcessIdempotent(context.Background(), "issuer", "owner", "cred", "thread-1")
+	require.NoError(t, err)
+	require.Same(t, results[0], rc)
+	require.Equal(t, int32(1), calls.Load())
+
+	// another thread or another owner is a new refresh
+	rc, err = rs.ProcessIdempotent(context.Background(), "issuer", "owner", "cred", "thread-2")
+	require.NoError(t, err)
+	require.Equal(t, "cred-2", rc.ID)
+	rc, err = rs.ProcessIdempotent(context.Background(), "issuer", "another", "cred", "thread-1")
+	require.NoError(t, err)
+	require.Equal(t, "cred-3", rc.ID)
+
+	// failures are not stored
+	_, err = rs.ProcessIdempotent(context.Background(), "issuer", "owner", "failing", "thread-1")
+	require.Error(t, err)
+	_, err = rs.ProcessIdempotent(context.Background(), "issuer", "owner", "failing", "thread-1")
+	require.Error(t, err)
+	require.Equal(t, int32(5), calls.Load())
+}
+
+func TestProcessIdempotent_CallerCanceled(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan struct{})
+	rs := NewRefreshService(nil, nil, nil, WithResultStore(nil, time.Minute))
+	rs.process = func(ctx context.Context, _, _, id, _ string) (*verifiable.W3CCredential, error) {
+		defer close(done)
+		<-release
+		// the refresh isn't canceled with the first caller
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return &verifiable.W3CCredential{ID: id}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := rs.ProcessIdempotent(ctx, "issuer", "owner", "cred", "thread")
+	require.ErrorIs(t, err, context.Canceled)
+
+	close(release)
+	<-done
+	require.Eventually(t, func() bool {
+		_, ok := rs.storedResult(idempotencyKey("issuer", "owner", "cred", "thread"))
+		return ok
+	}, time.Second, time.Millisecond)
+}
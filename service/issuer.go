@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -16,6 +17,7 @@ var (
 	ErrIssuerNotSupported = errors.New("issuer is not supported")
 	ErrGetClaim           = errors.New("failed to get claim")
 	ErrCreateClaim        = errors.New("failed to create claim")
+	ErrRevokeClaim        = errors.New("failed to revoke claim")
 )
 
 // IssuerService is service for communication with issuer node
@@ -40,14 +42,15 @@ func NewIssuerService(
 	}
 }
 
-func (is *IssuerService) GetClaimByID(issuerDID, claimID string) (*verifiable.W3CCredential, error) {
+func (is *IssuerService) GetClaimByID(ctx context.Context, issuerDID, claimID string) (*verifiable.W3CCredential, error) {
 	issuerNode, err := is.getIssuerURL(issuerDID)
 	if err != nil {
 		return nil, err
 	}
 	logger.DefaultLogger.Infof("use issuer node '%s' for issuer '%s'", issuerNode, issuerDID)
 
-	getRequest, err := http.NewRequest(
+	getRequest, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodGet,
 		fmt.Sprintf("%s/v2/identities/%s/credentials/%s", issuerNode, issuerDID, claimID),
 		http.NoBody,
@@ -85,7 +88,7 @@ func (is *IssuerService) GetClaimByID(issuerDID, claimID string) (*verifiable.W3
 	return presentation.VC, nil
 }
 
-func (is *IssuerService) CreateCredential(issuerDID string, credentialRequest credentialRequest) (
+func (is *IssuerService) CreateCredential(ctx context.Context, issuerDID string, credentialRequest CredentialRequest) (
 	id string,
 	err error,
 ) {
@@ -102,7 +105,8 @@ func (is *IssuerService) CreateCredential(issuerDID string, credentialRequest cr
 			"credential request serialization error")
 	}
 
-	postRequest, err := http.NewRequest(
+	postRequest, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodPost,
 		fmt.Sprintf("%s/v2/identities/%s/credentials", issuerNode, issuerDID),
 		body,
@@ -138,6 +142,80 @@ func (is *IssuerService) CreateCredential(issuerDID string, credentialRequest cr
 	return responseBody.ID, nil
 }
 
+// RevokeCredential revokes the credential with the revocation nonce.
+func (is *IssuerService) RevokeCredential(ctx context.Context, issuerDID string, nonce uint64) error {
+	issuerNode, err := is.getIssuerURL(issuerDID)
+	if err != nil {
+		return err
+	}
+	logger.DefaultLogger.Infof("revoke credential with nonce '%d' of issuer '%s'", nonce, issuerDID)
+
+	postRequest, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("%s/v2/identities/%s/credentials/revoke/%d", issuerNode, issuerDID, nonce),
+		http.NoBody,
+	)
+	if err != nil {
+		return errors.Wrapf(ErrRevokeClaim,
+			"failed to create http request: '%v'", err)
+	}
+	if err := is.setBasicAuth(issuerDID, postRequest); err != nil {
+		return err
+	}
+
+	resp, err := is.do.Do(postRequest)
+	if err != nil {
+		return errors.Wrapf(ErrRevokeClaim,
+			"failed http POST request: %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return errors.Wrapf(ErrRevokeClaim,
+			"invalid status code: '%d'", resp.StatusCode)
+	}
+	return nil
+}
+
+// DeleteCredential deletes the credential from the issuer node.
+func (is *IssuerService) DeleteCredential(ctx context.Context, issuerDID, claimID string) error {
+	issuerNode, err := is.getIssuerURL(issuerDID)
+	if err != nil {
+		return err
+	}
+	logger.DefaultLogger.Infof("delete credential '%s' of issuer '%s'", claimID, issuerDID)
+
+	deleteRequest, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodDelete,
+		fmt.Sprintf("%s/v2/identities/%s/credentials/%s", issuerNode, issuerDID, claimID),
+		http.NoBody,
+	)
+	if err != nil {
+		return errors.Wrapf(ErrRevokeClaim,
+			"failed to create http request: '%v'", err)
+	}
+	if err := is.setBasicAuth(issuerDID, deleteRequest); err != nil {
+		return err
+	}
+
+	resp, err := is.do.Do(deleteRequest)
+	if err != nil {
+		return errors.Wrapf(ErrRevokeClaim,
+			"failed http DELETE request: %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return errors.Wrapf(ErrRevokeClaim,
+			"invalid status code: '%d'", resp.StatusCode)
+	}
+	return nil
+}
+
 func (is *IssuerService) getIssuerURL(issuerDID string) (string, error) {
 	url, ok := is.supportedIssuers[issuerDID]
 	if !ok {
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuerService_ContextCanceled(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	is := NewIssuerService(map[string]string{"*": srv.URL}, nil, srv.Client())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := is.GetClaimByID(ctx, "did:iden3:issuer", "claim-id")
+	require.ErrorIs(t, err, ErrGetClaim)
+	require.ErrorContains(t, err, context.DeadlineExceeded.Error())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = is.CreateCredential(ctx, "did:iden3:issuer", CredentialRequest{})
+	require.ErrorIs(t, err, ErrCreateClaim)
+	require.ErrorContains(t, err, context.DeadlineExceeded.Error())
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrRefreshTooEarly       = errors.New("refresh is not allowed yet")
+	ErrRefreshTooFrequent    = errors.New("refresh is too frequent")
+	ErrRefreshLimitExceeded  = errors.New("refresh limit exceeded")
+	errCredentialNotExpiring = errors.New("credential doesn't expire")
+)
+
+// RefreshCounter counts refreshes of credentials. The key identifies a credential
+// across refreshes, since every refresh issues a credential with a new ID.
+type RefreshCounter interface {
+	Count(ctx context.Context, key string) (int, error)
+	// Reserve counts a refresh of the credential if it was refreshed less than limit times.
+	// A zero limit means no limit. False is returned if the limit is reached. The check
+	// and the count must be atomic, so concurrent refreshes can't exceed the limit.
+	Reserve(ctx context.Context, key string, limit int) (bool, error)
+	// Release uncounts a reserved refresh that failed.
+	Release(ctx context.Context, key string) error
+}
+
+type memoryRefreshCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewMemoryRefreshCounter returns a refresh counter that keeps counts in memory.
+// The counts are not persistent: they are lost on restart, so maxRefreshes
+// limits refreshes only since the start of the service.
+func NewMemoryRefreshCounter() RefreshCounter {
+	return &memoryRefreshCounter{
+		counts: make(map[string]int),
+	}
+}
+
+func (c *memoryRefreshCounter) Count(_ context.Context, key string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[key], nil
+}
+
+func (c *memoryRefreshCounter) Reserve(_ context.Context, key string, limit int) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if limit > 0 && c.counts[key] >= limit {
+		return false, nil
+	}
+	c.counts[key]++
+	return true, nil
+}
+
+func (c *memoryRefreshCounter) Release(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts[key] <= 1 {
+		delete(c.counts, key)
+		return nil
+	}
+	c.counts[key]--
+	return nil
+}
+
+type storageRefreshCounter struct {
+	store storage.Storage
+}
+
+// NewStorageRefreshCounter returns a refresh counter that keeps counts in the storage.
+// The counts survive restarts and are shared by instances of the service that use the same database.
+func NewStorageRefreshCounter(store storage.Storage) RefreshCounter {
+	return &storageRefreshCounter{store: store}
+}
+
+func (c *storageRefreshCounter) Count(ctx context.Context, key string) (int, error) {
+	return c.store.CountRefreshes(ctx, key)
+}
+
+func (c *storageRefreshCounter) Reserve(ctx context.Context, key string, limit int) (bool, error) {
+	return c.store.ReserveRefresh(ctx, key, limit)
+}
+
+func (c *storageRefreshCounter) Release(ctx context.Context, key string) error {
+	return c.store.ReleaseRefresh(ctx, key)
+}
+
+// WithRefreshCounter sets the counter used by the maxRefreshes policy.
+// By default refreshes are counted in memory.
+func WithRefreshCounter(counter RefreshCounter) Option {
+	return func(rs *RefreshService) {
+		rs.refreshCounter = counter
+	}
+}
+
+// refreshKey identifies the credential across refreshes by the issuer,
+// the credential subject and the credential type.
+func refreshKey(credential *verifiable.W3CCredential, credentialType string) string {
+	return fmt.Sprintf("%s|%v|%s", credential.Issuer, credential.CredentialSubject["id"], credentialType)
+}
+
+// applyRefreshPolicy returns an error if the policy doesn't allow to refresh the credential now.
+// Otherwise the refresh is counted and release must be called if the refresh fails.
+func (rs *RefreshService) applyRefreshPolicy(
+	ctx context.Context,
+	policy providers.RefreshPolicy,
+	credential *verifiable.W3CCredential,
+	key string,
+	now time.Time,
+) (release func(), err error) {
+	if !policy.AllowNotExpired {
+		if credential.Expiration == nil {
+			return nil, errors.Wrapf(ErrRefreshTooEarly, "credential '%s': %v", credential.ID, errCredentialNotExpiring)
+		}
+		allowedFrom := credential.Expiration.Add(-policy.RefreshWindow)
+		if now.Before(allowedFrom) {
+			return nil, errors.Wrapf(ErrRefreshTooEarly, "credential '%s' can be refreshed from %s",
+				credential.ID, allowedFrom.UTC().Format(time.RFC3339))
+		}
+	}
+	if policy.MinInterval > 0 && credential.IssuanceDate != nil {
+		if next := credential.IssuanceDate.Add(policy.MinInterval); now.Before(next) {
+			return nil, errors.Wrapf(ErrRefreshTooFrequent, "credential '%s' can be refreshed from %s",
+				credential.ID, next.UTC().Format(time.RFC3339))
+		}
+	}
+	ok, err := rs.refreshCounter.Reserve(ctx, key, policy.MaxRefreshes)
+	if err != nil {
+		return nil, errors.Errorf("failed to count refreshes: %v", err)
+	}
+	if !ok {
+		return nil, errors.Wrapf(ErrRefreshLimitExceeded, "credential '%s' was refreshed %d times",
+			credential.ID, policy.MaxRefreshes)
+	}
+	return func() {
+		if err := rs.refreshCounter.Release(context.WithoutCancel(ctx), key); err != nil {
+			logger.DefaultLogger.Errorf("failed to uncount refresh of credential '%s': %v", credential.ID, err)
+		}
+	}, nil
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyRefreshPolicy(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		v := now.Add(d)
+		return &v
+	}
+
+	tests := []struct {
+		name          string
+		policy        providers.RefreshPolicy
+		expiration    *time.Time
+		issuance      *time.Time
+		refreshes     int
+		expectedError error
+	}{
+		{
+			name:       "Expired",
+			expiration: at(-time.Minute),
+		},
+		{
+			name:          "Not expired",
+			expiration:    at(time.Minute),
+			expectedError: ErrRefreshTooEarly,
+		},
+		{
+			name:          "No expiration",
+			expectedError: ErrRefreshTooEarly,
+		},
+		{
+			name:       "In the refresh window",
+			policy:     providers.RefreshPolicy{RefreshWindow: time.Hour},
+			expiration: at(time.Minute),
+		},
+		{
+			name:          "Before the refresh window",
+			policy:        providers.RefreshPolicy{RefreshWindow: time.Hour},
+			expiration:    at(2 * time.Hour),
+			expectedError: ErrRefreshTooEarly,
+		},
+		{
+			name:       "Not expired is allowed",
+			policy:     providers.RefreshPolicy{AllowNotExpired: true},
+			expiration: at(24 * time.Hour),
+		},
+		{
+			name:          "Too frequent",
+			policy:        providers.RefreshPolicy{AllowNotExpired: true, MinInterval: time.Hour},
+			issuance:      at(-time.Minute),
+			expectedError: ErrRefreshTooFrequent,
+		},
+		{
+			name:     "After the minimal interval",
+			policy:   providers.RefreshPolicy{AllowNotExpired: true, MinInterval: time.Hour},
+			issuance: at(-2 * time.Hour),
+		},
+		{
+			name:      "Under the limit",
+			policy:    providers.RefreshPolicy{AllowNotExpired: true, MaxRefreshes: 2},
+			refreshes: 1,
+		},
+		{
+			name:          "Limit exceeded",
+			policy:        providers.RefreshPolicy{AllowNotExpired: true, MaxRefreshes: 2},
+			refreshes:     2,
+			expectedError: ErrRefreshLimitExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := NewRefreshService(nil, nil, nil)
+			credential := &verifiable.W3CCredential{
+				ID:                "urn:uuid:1",
+				Issuer:            "did:iden3:issuer",
+				Expiration:        tt.expiration,
+				IssuanceDate:      tt.issuance,
+				CredentialSubject: map[string]interface{}{"id": "did:iden3:alice"},
+			}
+			key := refreshKey(credential, "urn:test:Balance")
+			for i := 0; i < tt.refreshes; i++ {
+				_, err := rs.refreshCounter.Reserve(context.Background(), key, 0)
+				require.NoError(t, err)
+			}
+
+			release, err := rs.applyRefreshPolicy(context.Background(), tt.policy, credential, key, now)
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+				requireCount(t, rs.refreshCounter, key, tt.refreshes)
+				return
+			}
+			require.NoError(t, err)
+			requireCount(t, rs.refreshCounter, key, tt.refreshes+1)
+			release()
+			requireCount(t, rs.refreshCounter, key, tt.refreshes)
+		})
+	}
+}
+
+func TestRefreshCounter_Reserve(t *testing.T) {
+	tests := []struct {
+		name    string
+		counter func(t *testing.T) RefreshCounter
+	}{
+		{
+			name: "Memory",
+			counter: func(*testing.T) RefreshCounter {
+				return NewMemoryRefreshCounter()
+			},
+		},
+		{
+			name: "Storage",
+			counter: func(t *testing.T) RefreshCounter {
+				store, err := storage.Open(context.Background(), "sqlite://"+filepath.Join(t.TempDir(), "history.db"))
+				require.NoError(t, err)
+				t.Cleanup(func() { _ = store.Close() })
+				return NewStorageRefreshCounter(store)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := tt.counter(t)
+			const limit = 3
+
+			var (
+				wg       sync.WaitGroup
+				reserved atomic.Int32
+			)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ok, err := counter.Reserve(context.Background(), "key", limit)
+					require.NoError(t, err)
+					if ok {
+						reserved.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+			require.EqualValues(t, limit, reserved.Load())
+			requireCount(t, counter, "key", limit)
+
+			// a released refresh frees the slot
+			require.NoError(t, counter.Release(context.Background(), "key"))
+			ok, err := counter.Reserve(context.Background(), "key", limit)
+			require.NoError(t, err)
+			require.True(t, ok)
+			ok, err = counter.Reserve(context.Background(), "key", limit)
+			require.NoError(t, err)
+			require.False(t, ok)
+		})
+	}
+}
+
+func requireCount(t *testing.T, counter RefreshCounter, key string, expected int) {
+	t.Helper()
+	n, err := counter.Count(context.Background(), key)
+	require.NoError(t, err)
+	require.Equal(t, expected, n)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+)
+
+// ProbeResult is the result of a call of the data provider.
+type ProbeResult struct {
+	CredentialType string
+	Provider       string
+	// Fields are names of the fields returned by the provider.
+	Fields   []string
+	Duration time.Duration
+	Err      error
+}
+
+// Probe calls the data provider of the credential type with the credential, like
+// the refresh does, but the fields are not used. If the credential is nil, a credential
+// with only the subject type is used, so providers that depend on subject fields may fail.
+// The error is returned only if there is no provider for the credential type.
+func (rs *RefreshService) Probe(
+	ctx context.Context,
+	credentialType string,
+	credential *verifiable.W3CCredential,
+) (ProbeResult, error) {
+	provider, settings, err := rs.providers.Get(credentialType)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	if credential == nil {
+		credential = probeCredential(credentialType)
+	}
+
+	res := ProbeResult{
+		CredentialType: credentialType,
+		Provider:       providers.Describe(provider),
+	}
+	start := time.Now()
+	fields, err := rs.provide(ctx, provider, settings, credential)
+	res.Duration = time.Since(start)
+	if err != nil {
+		res.Err = err
+		return res, nil
+	}
+	for name := range fields {
+		res.Fields = append(res.Fields, name)
+	}
+	sort.Strings(res.Fields)
+	return res, nil
+}
+
+// probeCredential returns a credential with the subject type from the fragment of the credential type.
+func probeCredential(credentialType string) *verifiable.W3CCredential {
+	subjectType := credentialType
+	if i := strings.LastIndex(credentialType, "#"); i >= 0 {
+		subjectType = credentialType[i+1:]
+	}
+	return &verifiable.W3CCredential{
+		ID: "urn:uuid:probe",
+		CredentialSubject: map[string]interface{}{
+			"type": subjectType,
+		},
+	}
+}
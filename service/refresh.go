@@ -3,10 +3,13 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"reflect"
 	"strings"
 	"time"
 
-	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/0xPolygonID/refresh-service/providers"
+	"github.com/0xPolygonID/refresh-service/providers/cache"
+	"github.com/0xPolygonID/refresh-service/storage"
 	core "github.com/iden3/go-iden3-core/v2"
 	jsonproc "github.com/iden3/go-schema-processor/v2/json"
 	"github.com/iden3/go-schema-processor/v2/merklize"
@@ -14,6 +17,7 @@ import (
 	"github.com/iden3/go-schema-processor/v2/verifiable"
 	"github.com/piprate/json-gold/ld"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
 )
 
 var (
@@ -24,22 +28,114 @@ var (
 type RefreshService struct {
 	issuerService  *IssuerService
 	documentLoader ld.DocumentLoader
-	providers      flexiblehttp.FactoryFlexibleHTTP
+	providers      *providers.Registry
+
+	refreshTimeout  time.Duration
+	issuerTimeout   time.Duration
+	providerTimeout time.Duration
+
+	refreshCounter   RefreshCounter
+	revokeSuperseded bool
+
+	inflight  singleflight.Group
+	results   cache.Cache
+	resultTTL time.Duration
+	// process is refresh, it is replaced in tests of ProcessIdempotent
+	process func(ctx context.Context, issuer, owner, id, threadID string) (*verifiable.W3CCredential, error)
+
+	history   storage.Storage
+	errorCode func(error) int
+}
+
+// Option configures the RefreshService.
+type Option func(*RefreshService)
+
+// WithRefreshTimeout limits the duration of the whole refresh process.
+func WithRefreshTimeout(timeout time.Duration) Option {
+	return func(rs *RefreshService) {
+		rs.refreshTimeout = timeout
+	}
+}
+
+// WithIssuerTimeout limits the duration of every request to the issuer node.
+func WithIssuerTimeout(timeout time.Duration) Option {
+	return func(rs *RefreshService) {
+		rs.issuerTimeout = timeout
+	}
+}
+
+// WithProviderTimeout limits the duration of the data provider call.
+// The timeout from the provider settings takes precedence.
+func WithProviderTimeout(timeout time.Duration) Option {
+	return func(rs *RefreshService) {
+		rs.providerTimeout = timeout
+	}
 }
 
 func NewRefreshService(
 	issuerService *IssuerService,
 	decumentLoader ld.DocumentLoader,
-	providers flexiblehttp.FactoryFlexibleHTTP,
+	providers *providers.Registry,
+	opts ...Option,
 ) *RefreshService {
-	return &RefreshService{
+	rs := &RefreshService{
 		issuerService:  issuerService,
 		documentLoader: decumentLoader,
 		providers:      providers,
 	}
+	for _, opt := range opts {
+		opt(rs)
+	}
+	if rs.refreshCounter == nil {
+		rs.refreshCounter = NewMemoryRefreshCounter()
+	}
+	rs.process = rs.refresh
+	return rs
 }
 
-type credentialRequest struct {
+// withTimeout returns a context with the timeout. A zero timeout means no limit
+// other than the deadline of the parent context.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (rs *RefreshService) getClaimByID(ctx context.Context, issuer, id string) (*verifiable.W3CCredential, error) {
+	ctx, cancel := withTimeout(ctx, rs.issuerTimeout)
+	defer cancel()
+	defer trackIssuer(ctx, time.Now())
+	return rs.issuerService.GetClaimByID(ctx, issuer, id)
+}
+
+func (rs *RefreshService) createCredential(ctx context.Context, issuer string, request CredentialRequest) (string, error) {
+	ctx, cancel := withTimeout(ctx, rs.issuerTimeout)
+	defer cancel()
+	defer trackIssuer(ctx, time.Now())
+	return rs.issuerService.CreateCredential(ctx, issuer, request)
+}
+
+func (rs *RefreshService) provide(
+	ctx context.Context,
+	provider providers.Provider,
+	settings providers.Settings,
+	credential *verifiable.W3CCredential,
+) (map[string]interface{}, error) {
+	timeout := rs.providerTimeout
+	if settings.Timeout > 0 {
+		timeout = settings.Timeout
+	}
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	defer func(start time.Time) {
+		attemptFrom(ctx).ProviderDuration += time.Since(start)
+	}(time.Now())
+	return provider.Provide(ctx, credential)
+}
+
+// CredentialRequest is the request to issue the refreshed credential.
+type CredentialRequest struct {
 	CredentialSchema  string                     `json:"credentialSchema"`
 	Type              string                     `json:"type"`
 	CredentialSubject map[string]interface{}     `json:"credentialSubject"`
@@ -53,7 +149,10 @@ func (rs *RefreshService) Process(
 	ctx context.Context,
 	issuer, owner, id string) (
 	*verifiable.W3CCredential, error) {
-	credential, err := rs.issuerService.GetClaimByID(issuer, id)
+	ctx, cancel := withTimeout(ctx, rs.refreshTimeout)
+	defer cancel()
+
+	credential, err := rs.getClaimByID(ctx, issuer, id)
 	if err != nil {
 		return nil, err
 	}
@@ -70,65 +169,128 @@ func (rs *RefreshService) Process(
 			errors.Wrapf(ErrCredentialNotUpdatable, "credential '%s': %v", credential.ID, err)
 	}
 
-	credentialBytes, err := json.Marshal(credential)
+	credentialRequest, release, err := rs.buildCredentialRequest(ctx, credential)
 	if err != nil {
 		return nil, err
 	}
+
+	var rc *verifiable.W3CCredential
+	if rs.revokeSuperseded {
+		rc, err = rs.issueAndRevoke(ctx, issuer, credentialRequest, *credentialRequest.RevNonce)
+	} else {
+		rc, err = rs.issue(ctx, issuer, credentialRequest)
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return rc, nil
+}
+
+// issue issues the refreshed credential with the revocation nonce of the superseded one.
+func (rs *RefreshService) issue(
+	ctx context.Context,
+	issuer string,
+	request CredentialRequest,
+) (*verifiable.W3CCredential, error) {
+	refreshedID, err := rs.createCredential(ctx, issuer, request)
+	if err != nil {
+		return nil, err
+	}
+	return rs.getClaimByID(ctx, issuer, refreshedID)
+}
+
+// Simulate runs the refresh pipeline without the issuer node: the fields are
+// fetched from the data provider and merged into the credential, and the request
+// that would be sent to the issuer is returned. The ownership isn't checked
+// and the refresh isn't counted by the maxRefreshes policy.
+func (rs *RefreshService) Simulate(ctx context.Context, credential *verifiable.W3CCredential) (CredentialRequest, error) {
+	ctx, cancel := withTimeout(ctx, rs.refreshTimeout)
+	defer cancel()
+
+	if err := isUpdatable(credential); err != nil {
+		return CredentialRequest{},
+			errors.Wrapf(ErrCredentialNotUpdatable,
+				"credential '%s': %v", credential.ID, err)
+	}
+	credentialRequest, release, err := rs.buildCredentialRequest(ctx, credential)
+	if err != nil {
+		return CredentialRequest{}, err
+	}
+	release()
+	return credentialRequest, nil
+}
+
+// buildCredentialRequest checks the refresh policy, updates the credential with the fields
+// from the data provider and builds the request to issue the refreshed credential.
+// The refresh is counted, release uncounts it if the credential isn't issued.
+func (rs *RefreshService) buildCredentialRequest(
+	ctx context.Context,
+	credential *verifiable.W3CCredential,
+) (_ CredentialRequest, _ func(), err error) {
+	credentialBytes, err := json.Marshal(credential)
+	if err != nil {
+		return CredentialRequest{}, nil, err
+	}
 	credentialType, err := merklize.Options{
 		DocumentLoader: rs.documentLoader,
 	}.TypeIDFromContext(credentialBytes, credential.CredentialSubject["type"].(string))
 	if err != nil {
-		return nil, err
+		return CredentialRequest{}, nil, err
 	}
 
-	flexibleHTTP, err := rs.providers.ProduceFlexibleHTTP(credentialType)
+	provider, settings, err := rs.providers.Get(credentialType)
 	if err != nil {
-		return nil,
+		return CredentialRequest{}, nil,
 			errors.Wrapf(ErrCredentialNotUpdatable,
 				"for credential '%s' not possible to find a data provider: %v", credential.ID, err)
+	}
+	attempt := attemptFrom(ctx)
+	attempt.CredentialType = credentialType
+	attempt.Provider = providers.Describe(provider)
 
+	key := refreshKey(credential, credentialType)
+	release, err := rs.applyRefreshPolicy(ctx, settings.Policy, credential, key, time.Now())
+	if err != nil {
+		return CredentialRequest{}, nil, err
 	}
-	updatedFields, err := flexibleHTTP.Provide(credential.CredentialSubject)
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
+	updatedFields, err := rs.provide(ctx, provider, settings, credential)
 	if err != nil {
-		return nil, err
+		return CredentialRequest{}, nil, err
 	}
 
 	if err := rs.isUpdatedIndexSlots(ctx, credential,
 		credential.CredentialSubject, updatedFields); err != nil {
-		return nil,
+		return CredentialRequest{}, nil,
 			errors.Wrapf(ErrCredentialNotUpdatable,
 				"for credential '%s' index slots parsing process error: %v", credential.ID, err)
 	}
 
-	for k, v := range updatedFields {
-		credential.CredentialSubject[k] = v
+	mergeFields(credential.CredentialSubject, updatedFields)
+	if err := rs.validateCredentialSchema(credential); err != nil {
+		return CredentialRequest{}, nil, err
 	}
 
 	revNonce, err := extractRevocationNonce(credential)
 	if err != nil {
-		return nil, err
+		return CredentialRequest{}, nil, err
 	}
 
-	credentialRequest := credentialRequest{
+	return CredentialRequest{
 		CredentialSchema:  credential.CredentialSchema.ID,
 		Type:              credential.CredentialSubject["type"].(string),
 		CredentialSubject: credential.CredentialSubject,
-		Expiration:        time.Now().Add(flexibleHTTP.Settings.TimeExpiration).Unix(),
+		Expiration:        time.Now().Add(settings.TimeExpiration).Unix(),
 		RefreshService:    credential.RefreshService,
 		RevNonce:          &revNonce,
 		DisplayMethod:     credential.DisplayMethod,
-	}
-
-	refreshedID, err := rs.issuerService.CreateCredential(issuer, credentialRequest)
-	if err != nil {
-		return nil, err
-	}
-	rc, err := rs.issuerService.GetClaimByID(issuer, refreshedID)
-	if err != nil {
-		return nil, err
-	}
-
-	return rc, nil
+	}, release, nil
 }
 
 func (rs *RefreshService) loadContexts(contexts []string) ([]byte, error) {
@@ -158,10 +320,9 @@ func (rs *RefreshService) loadContexts(contexts []string) ([]byte, error) {
 	return json.Marshal(res)
 }
 
+// isUpdatable checks the credential itself. The expiration is checked
+// by the refresh policy of the credential type.
 func isUpdatable(credential *verifiable.W3CCredential) error {
-	if credential.Expiration.After(time.Now()) {
-		return errors.New("not expired")
-	}
 	if credential.CredentialSubject["id"] == "" {
 		return errors.New("credential subject does not have an id")
 	}
@@ -216,7 +377,7 @@ func (rs *RefreshService) isUpdatedIndexSlots(
 			} else if err != nil {
 				return err
 			}
-			if (slotIndex == 2 || slotIndex == 3) && v != newValues[k] {
+			if (slotIndex == 2 || slotIndex == 3) && !reflect.DeepEqual(v, newValues[k]) {
 				return nil
 			}
 		}
@@ -224,6 +385,20 @@ func (rs *RefreshService) isUpdatedIndexSlots(
 	return errIndexSlotsNotUpdated
 }
 
+// mergeFields sets the updated fields to the credential subject.
+// Nested objects are merged, so a provider can update a part of an object.
+func mergeFields(subject, updatedFields map[string]interface{}) {
+	for k, v := range updatedFields {
+		if updated, ok := v.(map[string]interface{}); ok {
+			if existing, ok := subject[k].(map[string]interface{}); ok {
+				mergeFields(existing, updated)
+				continue
+			}
+		}
+		subject[k] = v
+	}
+}
+
 func extractRevocationNonce(credential *verifiable.W3CCredential) (uint64, error) {
 	credentialStatusInfo, ok := credential.CredentialStatus.(map[string]interface{})
 	if !ok {
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
+	"github.com/0xPolygonID/refresh-service/providers"
 	"github.com/iden3/go-schema-processor/v2/verifiable"
 	"github.com/piprate/json-gold/ld"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -146,3 +149,138 @@ func TestIsUpdatedIndexSlot_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestRefreshService_ProviderTimeout(t *testing.T) {
+	tests := []struct {
+		name            string
+		providerTimeout time.Duration
+		settings        providers.Settings
+		expectedTimeout time.Duration
+	}{
+		{
+			name:            "Service-wide timeout",
+			providerTimeout: time.Minute,
+			expectedTimeout: time.Minute,
+		},
+		{
+			name:            "Timeout from provider settings",
+			providerTimeout: time.Minute,
+			settings:        providers.Settings{Timeout: time.Second},
+			expectedTimeout: time.Second,
+		},
+		{
+			name: "No timeout",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := NewRefreshService(nil, nil, nil, WithProviderTimeout(tt.providerTimeout))
+			provider := providers.ProviderFunc(func(ctx context.Context, _ *verifiable.W3CCredential) (map[string]interface{}, error) {
+				deadline, ok := ctx.Deadline()
+				if tt.expectedTimeout == 0 {
+					require.False(t, ok)
+					return nil, nil
+				}
+				require.True(t, ok)
+				require.WithinDuration(t, time.Now().Add(tt.expectedTimeout), deadline, time.Second/2)
+				return nil, nil
+			})
+			_, err := rs.provide(context.Background(), provider, tt.settings, &verifiable.W3CCredential{})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMergeFields(t *testing.T) {
+	subject := map[string]interface{}{
+		"id":      "did:iden3:alice",
+		"balance": 1,
+		"address": map[string]interface{}{
+			"city": "Kyiv",
+			"zip":  1001,
+		},
+	}
+	mergeFields(subject, map[string]interface{}{
+		"balance": 2,
+		"address": map[string]interface{}{
+			"zip": 1002,
+		},
+		"tags": []interface{}{"kyc"},
+	})
+	require.Equal(t, map[string]interface{}{
+		"id":      "did:iden3:alice",
+		"balance": 2,
+		"address": map[string]interface{}{
+			"city": "Kyiv",
+			"zip":  1002,
+		},
+		"tags": []interface{}{"kyc"},
+	}, subject)
+}
+
+const balanceContext = `{
+  "@context": {
+    "@protected": true,
+    "@version": 1.1,
+    "Balance": {
+      "@id": "urn:test#Balance",
+      "@context": {
+        "balance": {"@id": "urn:test#balance", "@type": "http://www.w3.org/2001/XMLSchema#integer"}
+      }
+    }
+  }
+}`
+
+func TestRefreshService_SimulatePolicy(t *testing.T) {
+	errProviderIsDown := errors.New("provider is down")
+	expiration := time.Now().Add(time.Hour)
+	tests := []struct {
+		name          string
+		policy        providers.RefreshPolicy
+		refreshes     int
+		expectedError error
+	}{
+		{
+			name:          "Not expired",
+			expectedError: ErrRefreshTooEarly,
+		},
+		{
+			name:          "Limit exceeded",
+			policy:        providers.RefreshPolicy{AllowNotExpired: true, MaxRefreshes: 1},
+			refreshes:     1,
+			expectedError: ErrRefreshLimitExceeded,
+		},
+		{
+			name:          "Failed refresh isn't counted",
+			policy:        providers.RefreshPolicy{AllowNotExpired: true, MaxRefreshes: 1},
+			expectedError: errProviderIsDown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := providers.NewRegistry()
+			require.NoError(t, registry.Register("urn:test#Balance",
+				providers.ProviderFunc(func(_ context.Context, _ *verifiable.W3CCredential) (map[string]interface{}, error) {
+					return nil, errProviderIsDown
+				}), providers.Settings{Policy: tt.policy}))
+			rs := NewRefreshService(nil, staticLoader{"https://example.com/balance.jsonld": balanceContext}, registry)
+
+			credential := &verifiable.W3CCredential{
+				ID:                "urn:uuid:1",
+				Context:           []string{"https://example.com/balance.jsonld"},
+				Issuer:            "did:iden3:issuer",
+				Expiration:        &expiration,
+				CredentialSubject: map[string]interface{}{"id": "did:iden3:alice", "type": "Balance"},
+			}
+			key := refreshKey(credential, "urn:test#Balance")
+			for i := 0; i < tt.refreshes; i++ {
+				_, err := rs.refreshCounter.Reserve(context.Background(), key, 0)
+				require.NoError(t, err)
+			}
+
+			_, err := rs.Simulate(context.Background(), credential)
+			require.ErrorIs(t, err, tt.expectedError)
+			requireCount(t, rs.refreshCounter, key, tt.refreshes)
+		})
+	}
+}
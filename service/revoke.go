@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// ErrRollbackClaim is returned if the refresh failed after the issuance
+// and the refreshed credential couldn't be revoked or deleted.
+var ErrRollbackClaim = errors.New("failed to roll back refreshed claim")
+
+// WithRevokeSuperseded issues the refreshed credential with a fresh revocation nonce
+// and revokes the superseded credential. By default the refreshed credential reuses
+// the revocation nonce of the superseded one.
+func WithRevokeSuperseded(revoke bool) Option {
+	return func(rs *RefreshService) {
+		rs.revokeSuperseded = revoke
+	}
+}
+
+func (rs *RefreshService) revokeCredential(ctx context.Context, issuer string, nonce uint64) error {
+	ctx, cancel := withTimeout(ctx, rs.issuerTimeout)
+	defer cancel()
+	defer trackIssuer(ctx, time.Now())
+	return rs.issuerService.RevokeCredential(ctx, issuer, nonce)
+}
+
+// issueAndRevoke issues the refreshed credential with a fresh revocation nonce,
+// then revokes the superseded credential. If any step after the issuance fails,
+// the refreshed credential is revoked, so the holder never has two valid credentials.
+func (rs *RefreshService) issueAndRevoke(
+	ctx context.Context,
+	issuer string,
+	request CredentialRequest,
+	supersededNonce uint64,
+) (*verifiable.W3CCredential, error) {
+	request.RevNonce = nil
+	refreshedID, err := rs.createCredential(ctx, issuer, request)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := rs.getClaimByID(ctx, issuer, refreshedID)
+	if err != nil {
+		return nil, rs.rollback(ctx, issuer, refreshedID, nil, err)
+	}
+	refreshedNonce, err := extractRevocationNonce(rc)
+	if err != nil {
+		return nil, rs.rollback(ctx, issuer, refreshedID, nil,
+			errors.Wrapf(ErrGetClaim, "refreshed credential '%s': %v", refreshedID, err))
+	}
+	if refreshedNonce == supersededNonce {
+		// the issuer node reused the nonce, the superseded credential
+		// can't be revoked without revoking the refreshed one
+		logger.DefaultLogger.Warnf("issuer '%s' reused revocation nonce '%d' for credential '%s'",
+			issuer, refreshedNonce, refreshedID)
+		return rc, nil
+	}
+
+	if err := rs.revokeCredential(ctx, issuer, supersededNonce); err != nil {
+		return nil, rs.rollback(ctx, issuer, refreshedID, &refreshedNonce,
+			errors.Wrapf(ErrRevokeClaim, "superseded credential with nonce '%d': %v", supersededNonce, err))
+	}
+	return rc, nil
+}
+
+// rollback revokes the refreshed credential if its nonce is known, otherwise the
+// credential is deleted. The rollback is done even if the refresh context is done.
+// cause is the error that failed the refresh. It is returned if the credential is
+// rolled back, otherwise ErrRollbackClaim is returned, since the refreshed credential is still valid.
+func (rs *RefreshService) rollback(ctx context.Context, issuer, refreshedID string, nonce *uint64, cause error) error {
+	ctx = context.WithoutCancel(ctx)
+	var err error
+	if nonce != nil {
+		err = rs.revokeCredential(ctx, issuer, *nonce)
+	} else {
+		ctx, cancel := withTimeout(ctx, rs.issuerTimeout)
+		defer cancel()
+		defer trackIssuer(ctx, time.Now())
+		err = rs.issuerService.DeleteCredential(ctx, issuer, refreshedID)
+	}
+	if err != nil {
+		logger.DefaultLogger.Errorf("failed to roll back refreshed credential '%s' of issuer '%s', "+
+			"it must be revoked manually: %v", refreshedID, issuer, err)
+		return errors.Wrapf(ErrRollbackClaim, "refreshed credential '%s': %v, the refresh failed: %v",
+			refreshedID, err, cause)
+	}
+	logger.DefaultLogger.Warnf("refreshed credential '%s' of issuer '%s' is rolled back", refreshedID, issuer)
+	return cause
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const testIssuer = "did:iden3:issuer"
+
+// issuerNode is a stand-in issuer node that keeps credentials in memory.
+type issuerNode struct {
+	mu        sync.Mutex
+	nextNonce uint64
+	nonces    map[string]uint64
+	revoked   map[uint64]bool
+	deleted   map[string]bool
+
+	failGet    bool
+	failDelete bool
+	failRevoke map[uint64]bool
+}
+
+func newIssuerNode() *issuerNode {
+	return &issuerNode{
+		nextNonce:  100,
+		nonces:     map[string]uint64{"old": 1},
+		revoked:    make(map[uint64]bool),
+		deleted:    make(map[string]bool),
+		failRevoke: make(map[uint64]bool),
+	}
+}
+
+func (n *issuerNode) handler() http.Handler {
+	r := chi.NewRouter()
+	r.Route("/v2/identities/{did}/credentials", func(r chi.Router) {
+		r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			var request CredentialRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			nonce := n.nextNonce
+			if request.RevNonce != nil {
+				nonce = *request.RevNonce
+			} else {
+				n.nextNonce++
+			}
+			id := fmt.Sprintf("new-%d", nonce)
+			n.nonces[id] = nonce
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(map[string]string{"id": id})
+		})
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			nonce, ok := n.nonces[chi.URLParam(r, "id")]
+			if !ok || n.failGet {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"vc": map[string]interface{}{
+					"id":               chi.URLParam(r, "id"),
+					"credentialStatus": map[string]interface{}{"revocationNonce": nonce},
+				},
+			})
+		})
+		r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			if n.failDelete {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			n.deleted[chi.URLParam(r, "id")] = true
+		})
+		r.Post("/revoke/{nonce}", func(w http.ResponseWriter, r *http.Request) {
+			nonce, err := strconv.ParseUint(chi.URLParam(r, "nonce"), 10, 64)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			if n.failRevoke[nonce] {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			n.revoked[nonce] = true
+			w.WriteHeader(http.StatusAccepted)
+		})
+	})
+	return r
+}
+
+func TestIssueAndRevoke(t *testing.T) {
+	tests := []struct {
+		name            string
+		prepare         func(n *issuerNode)
+		expectedError   error
+		expectedRevoked map[uint64]bool
+		expectedDeleted map[string]bool
+	}{
+		{
+			name:            "Superseded credential is revoked",
+			expectedRevoked: map[uint64]bool{1: true},
+			expectedDeleted: map[string]bool{},
+		},
+		{
+			name: "Refreshed credential is revoked if the superseded one can't be revoked",
+			prepare: func(n *issuerNode) {
+				n.failRevoke[1] = true
+			},
+			expectedError:   ErrRevokeClaim,
+			expectedRevoked: map[uint64]bool{100: true},
+			expectedDeleted: map[string]bool{},
+		},
+		{
+			name: "Refreshed credential is deleted if it can't be fetched",
+			prepare: func(n *issuerNode) {
+				n.failGet = true
+			},
+			expectedError:   ErrGetClaim,
+			expectedRevoked: map[uint64]bool{},
+			expectedDeleted: map[string]bool{"new-100": true},
+		},
+		{
+			name: "Failed revocation of the refreshed credential is reported",
+			prepare: func(n *issuerNode) {
+				n.failRevoke[1] = true
+				n.failRevoke[100] = true
+			},
+			expectedError:   ErrRollbackClaim,
+			expectedRevoked: map[uint64]bool{},
+			expectedDeleted: map[string]bool{},
+		},
+		{
+			name: "Failed deletion of the refreshed credential is reported",
+			prepare: func(n *issuerNode) {
+				n.failGet = true
+				n.failDelete = true
+			},
+			expectedError:   ErrRollbackClaim,
+			expectedRevoked: map[uint64]bool{},
+			expectedDeleted: map[string]bool{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := newIssuerNode()
+			if tt.prepare != nil {
+				tt.prepare(node)
+			}
+			srv := httptest.NewServer(node.handler())
+			defer srv.Close()
+
+			rs := NewRefreshService(
+				NewIssuerService(map[string]string{testIssuer: srv.URL}, nil, srv.Client()),
+				nil, nil,
+				WithRevokeSuperseded(true),
+			)
+			supersededNonce := uint64(1)
+			rc, err := rs.issueAndRevoke(context.Background(), testIssuer, CredentialRequest{
+				RevNonce: &supersededNonce,
+			}, supersededNonce)
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, "new-100", rc.ID)
+			}
+			require.Equal(t, tt.expectedRevoked, node.revoked)
+			require.Equal(t, tt.expectedDeleted, node.deleted)
+		})
+	}
+}
+
+func TestIssue_ReusesNonce(t *testing.T) {
+	node := newIssuerNode()
+	srv := httptest.NewServer(node.handler())
+	defer srv.Close()
+
+	rs := NewRefreshService(
+		NewIssuerService(map[string]string{testIssuer: srv.URL}, nil, srv.Client()),
+		nil, nil,
+	)
+	supersededNonce := uint64(1)
+	rc, err := rs.issue(context.Background(), testIssuer, CredentialRequest{RevNonce: &supersededNonce})
+	require.NoError(t, err)
+	require.Equal(t, "new-1", rc.ID)
+	require.Empty(t, node.revoked)
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"encoding/json"
+
+	jsonproc "github.com/iden3/go-schema-processor/v2/json"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrCredentialSchemaValidation  = errors.New("refreshed credential doesn't match the credential schema")
+	ErrCredentialSchemaUnavailable = errors.New("credential schema is unavailable")
+)
+
+// validateCredentialSchema validates the credential with the updated subject
+// against the JSON schema from credentialSchema.id. Credentials without
+// a schema are not validated. A schema that can't be loaded is reported as
+// ErrCredentialSchemaUnavailable.
+func (rs *RefreshService) validateCredentialSchema(credential *verifiable.W3CCredential) error {
+	if credential.CredentialSchema.ID == "" {
+		return nil
+	}
+	remoteDocument, err := rs.documentLoader.LoadDocument(credential.CredentialSchema.ID)
+	if err != nil {
+		return errors.Wrapf(ErrCredentialSchemaUnavailable,
+			"failed to load schema '%s': %v", credential.CredentialSchema.ID, err)
+	}
+	schemaBytes, err := json.Marshal(remoteDocument.Document)
+	if err != nil {
+		return errors.Wrapf(ErrCredentialSchemaUnavailable,
+			"failed to marshal schema '%s': %v", credential.CredentialSchema.ID, err)
+	}
+	credentialBytes, err := json.Marshal(credential)
+	if err != nil {
+		return errors.Errorf("failed to marshal credential: %v", err)
+	}
+	if err := (jsonproc.Validator{}).ValidateData(credentialBytes, schemaBytes); err != nil {
+		return errors.Wrapf(ErrCredentialSchemaValidation,
+			"credential '%s', schema '%s': %v", credential.ID, credential.CredentialSchema.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/piprate/json-gold/ld"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+const balanceSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["credentialSubject"],
+	"properties": {
+		"credentialSubject": {
+			"type": "object",
+			"required": ["id", "balance"],
+			"properties": {
+				"id": {"type": "string"},
+				"balance": {"type": "integer", "minimum": 0},
+				"currency": {"type": "string", "enum": ["MATIC", "ETH"]}
+			}
+		}
+	}
+}`
+
+type staticLoader map[string]string
+
+func (l staticLoader) LoadDocument(u string) (*ld.RemoteDocument, error) {
+	doc, ok := l[u]
+	if !ok {
+		return nil, errors.Errorf("document '%s' not found", u)
+	}
+	var document interface{}
+	if err := json.Unmarshal([]byte(doc), &document); err != nil {
+		return nil, err
+	}
+	return &ld.RemoteDocument{DocumentURL: u, Document: document}, nil
+}
+
+func TestValidateCredentialSchema(t *testing.T) {
+	tests := []struct {
+		name          string
+		schemaID      string
+		subject       map[string]interface{}
+		expectedErr   error
+		expectedError string
+	}{
+		{
+			name:     "Valid subject",
+			schemaID: "https://example.com/balance.json",
+			subject:  map[string]interface{}{"id": "did:iden3:alice", "balance": 100, "currency": "MATIC"},
+		},
+		{
+			name:    "No schema",
+			subject: map[string]interface{}{"balance": "wrong type"},
+		},
+		{
+			name:          "Wrong type",
+			expectedErr:   ErrCredentialSchemaValidation,
+			schemaID:      "https://example.com/balance.json",
+			subject:       map[string]interface{}{"id": "did:iden3:alice", "balance": "100"},
+			expectedError: "/credentialSubject/balance",
+		},
+		{
+			name:          "Not in enum",
+			expectedErr:   ErrCredentialSchemaValidation,
+			schemaID:      "https://example.com/balance.json",
+			subject:       map[string]interface{}{"id": "did:iden3:alice", "balance": 100, "currency": "BTC"},
+			expectedError: "/credentialSubject/currency",
+		},
+		{
+			name:          "Missing required field",
+			expectedErr:   ErrCredentialSchemaValidation,
+			schemaID:      "https://example.com/balance.json",
+			subject:       map[string]interface{}{"id": "did:iden3:alice"},
+			expectedError: "missing properties: 'balance'",
+		},
+		{
+			name:          "Schema not found",
+			expectedErr:   ErrCredentialSchemaUnavailable,
+			schemaID:      "https://example.com/unknown.json",
+			subject:       map[string]interface{}{"id": "did:iden3:alice"},
+			expectedError: "failed to load schema",
+		},
+	}
+	rs := NewRefreshService(nil, staticLoader{"https://example.com/balance.json": balanceSchema}, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rs.validateCredentialSchema(&verifiable.W3CCredential{
+				ID:                "urn:uuid:e342def6-620e-4394-8ea1-7448ea81bb72",
+				CredentialSubject: tt.subject,
+				CredentialSchema:  verifiable.CredentialSchema{ID: tt.schemaID, Type: "JsonSchema2023"},
+			})
+			if tt.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+}
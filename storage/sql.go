@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	// database drivers
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+type dialect struct {
+	driver     string
+	idColumn   string
+	timeColumn string
+	// placeholder returns the placeholder of the n-th argument, starting from 1
+	placeholder func(n int) string
+}
+
+var (
+	sqlite = dialect{
+		driver:      "sqlite3",
+		idColumn:    "INTEGER PRIMARY KEY AUTOINCREMENT",
+		timeColumn:  "DATETIME",
+		placeholder: func(int) string { return "?" },
+	}
+	postgres = dialect{
+		driver:      "pgx",
+		idColumn:    "BIGSERIAL PRIMARY KEY",
+		timeColumn:  "TIMESTAMP WITH TIME ZONE",
+		placeholder: func(n int) string { return fmt.Sprintf("$%d", n) },
+	}
+)
+
+func (d dialect) schema() []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS refresh_attempts (
+			id ` + d.idColumn + `,
+			issuer TEXT NOT NULL,
+			thread_id TEXT NOT NULL,
+			holder_did TEXT NOT NULL,
+			credential_id TEXT NOT NULL,
+			refreshed_id TEXT NOT NULL,
+			credential_type TEXT NOT NULL,
+			provider TEXT NOT NULL,
+			outcome TEXT NOT NULL,
+			error_code INTEGER NOT NULL,
+			error TEXT NOT NULL,
+			started_at ` + d.timeColumn + ` NOT NULL,
+			duration_ms BIGINT NOT NULL,
+			provider_duration_ms BIGINT NOT NULL,
+			issuer_duration_ms BIGINT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS refresh_attempts_started_at ON refresh_attempts (started_at)`,
+		`CREATE INDEX IF NOT EXISTS refresh_attempts_holder_did ON refresh_attempts (holder_did)`,
+		`CREATE INDEX IF NOT EXISTS refresh_attempts_credential_id ON refresh_attempts (credential_id)`,
+		`CREATE TABLE IF NOT EXISTS refresh_counts (
+			refresh_key TEXT PRIMARY KEY,
+			count INTEGER NOT NULL
+		)`,
+	}
+}
+
+// SQL keeps refresh attempts and refresh counts in a SQLite or Postgres database.
+type SQL struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+func openSQL(ctx context.Context, d dialect, dsn string) (*SQL, error) {
+	db, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, errors.Wrapf(ErrStorage, "failed to open %s database: %v", d.driver, err)
+	}
+	if d.driver == sqlite.driver {
+		// sqlite doesn't support concurrent writers
+		db.SetMaxOpenConns(1)
+	}
+	for _, stmt := range d.schema() {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			_ = db.Close()
+			return nil, errors.Wrapf(ErrStorage, "failed to create schema: %v", err)
+		}
+	}
+	return &SQL{db: db, dialect: d}, nil
+}
+
+func (s *SQL) SaveAttempt(ctx context.Context, a *Attempt) error {
+	placeholders := make([]string, 14)
+	for i := range placeholders {
+		placeholders[i] = s.dialect.placeholder(i + 1)
+	}
+	query := `INSERT INTO refresh_attempts (
+		issuer, thread_id, holder_did, credential_id, refreshed_id, credential_type, provider,
+		outcome, error_code, error, started_at, duration_ms, provider_duration_ms, issuer_duration_ms
+	) VALUES (` + strings.Join(placeholders, ", ") + `) RETURNING id`
+
+	err := s.db.QueryRowContext(ctx, query,
+		a.Issuer, a.ThreadID, a.HolderDID, a.CredentialID, a.RefreshedID, a.CredentialType, a.Provider,
+		a.Outcome, a.ErrorCode, a.Error, a.StartedAt.UTC(),
+		a.Duration.Milliseconds(), a.ProviderDuration.Milliseconds(), a.IssuerDuration.Milliseconds(),
+	).Scan(&a.ID)
+	if err != nil {
+		return errors.Wrapf(ErrStorage, "failed to save attempt: %v", err)
+	}
+	return nil
+}
+
+func (s *SQL) ListAttempts(ctx context.Context, filter Filter) ([]Attempt, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, condition+" "+s.dialect.placeholder(len(args)))
+	}
+	if filter.Issuer != "" {
+		where("issuer =", filter.Issuer)
+	}
+	if filter.HolderDID != "" {
+		where("holder_did =", filter.HolderDID)
+	}
+	if filter.CredentialID != "" {
+		where("credential_id =", filter.CredentialID)
+	}
+	if filter.CredentialType != "" {
+		where("credential_type =", filter.CredentialType)
+	}
+	if filter.Outcome != "" {
+		where("outcome =", filter.Outcome)
+	}
+	if !filter.Since.IsZero() {
+		where("started_at >=", filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		where("started_at <", filter.Until.UTC())
+	}
+
+	query := `SELECT id, issuer, thread_id, holder_did, credential_id, refreshed_id, credential_type, provider,
+		outcome, error_code, error, started_at, duration_ms, provider_duration_ms, issuer_duration_ms
+		FROM refresh_attempts`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	args = append(args, limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY started_at DESC, id DESC LIMIT %s OFFSET %s",
+		s.dialect.placeholder(len(args)-1), s.dialect.placeholder(len(args)))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrapf(ErrStorage, "failed to list attempts: %v", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	attempts := []Attempt{}
+	for rows.Next() {
+		var (
+			a                                  Attempt
+			duration, providerDuration, issuer int64
+		)
+		if err := rows.Scan(
+			&a.ID, &a.Issuer, &a.ThreadID, &a.HolderDID, &a.CredentialID, &a.RefreshedID, &a.CredentialType,
+			&a.Provider, &a.Outcome, &a.ErrorCode, &a.Error, &a.StartedAt,
+			&duration, &providerDuration, &issuer,
+		); err != nil {
+			return nil, errors.Wrapf(ErrStorage, "failed to read attempt: %v", err)
+		}
+		a.StartedAt = a.StartedAt.UTC()
+		a.Duration = time.Duration(duration) * time.Millisecond
+		a.ProviderDuration = time.Duration(providerDuration) * time.Millisecond
+		a.IssuerDuration = time.Duration(issuer) * time.Millisecond
+		attempts = append(attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(ErrStorage, "failed to list attempts: %v", err)
+	}
+	return attempts, nil
+}
+
+func (s *SQL) CountRefreshes(ctx context.Context, key string) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT count FROM refresh_counts WHERE refresh_key = `+s.dialect.placeholder(1), key,
+	).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrapf(ErrStorage, "failed to count refreshes: %v", err)
+	}
+	return count, nil
+}
+
+// ReserveRefresh checks the limit and counts the refresh in one UPDATE statement,
+// so concurrent reservations, also from other instances of the service, can't exceed the limit.
+func (s *SQL) ReserveRefresh(ctx context.Context, key string, limit int) (bool, error) {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO refresh_counts (refresh_key, count) VALUES (`+s.dialect.placeholder(1)+`, 0)
+		ON CONFLICT (refresh_key) DO NOTHING`, key)
+	if err != nil {
+		return false, errors.Wrapf(ErrStorage, "failed to reserve refresh: %v", err)
+	}
+
+	query := `UPDATE refresh_counts SET count = count + 1 WHERE refresh_key = ` + s.dialect.placeholder(1)
+	args := []interface{}{key}
+	if limit > 0 {
+		query += ` AND count < ` + s.dialect.placeholder(2)
+		args = append(args, limit)
+	}
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, errors.Wrapf(ErrStorage, "failed to reserve refresh: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrapf(ErrStorage, "failed to reserve refresh: %v", err)
+	}
+	return n == 1, nil
+}
+
+func (s *SQL) ReleaseRefresh(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE refresh_counts SET count = count - 1 WHERE refresh_key = `+s.dialect.placeholder(1)+` AND count > 0`,
+		key)
+	if err != nil {
+		return errors.Wrapf(ErrStorage, "failed to release refresh: %v", err)
+	}
+	return nil
+}
+
+func (s *SQL) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return errors.Wrapf(ErrStorage, "ping failed: %v", err)
+	}
+	return nil
+}
+
+func (s *SQL) Close() error {
+	return s.db.Close()
+}
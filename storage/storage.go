@@ -0,0 +1,111 @@
+// Package storage keeps the history of refresh attempts and the counts of credential refreshes.
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Outcomes of refresh attempts.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	// OutcomeDeduplicated is the outcome of a duplicate request that got the result
+	// of another refresh: the refresh in flight or the stored result.
+	OutcomeDeduplicated = "deduplicated"
+)
+
+var (
+	ErrUnsupportedStorage = errors.New("unsupported storage")
+	ErrStorage            = errors.New("storage error")
+)
+
+// Attempt is a record of a refresh attempt.
+type Attempt struct {
+	ID       int64
+	Issuer   string
+	ThreadID string
+	// HolderDID is the DID that requested the refresh.
+	HolderDID string
+	// CredentialID is the ID of the superseded credential.
+	CredentialID string
+	// RefreshedID is the ID of the refreshed credential. It is empty if the refresh failed.
+	RefreshedID    string
+	CredentialType string
+	// Provider describes the data provider that was used.
+	Provider  string
+	Outcome   string
+	ErrorCode int
+	Error     string
+
+	StartedAt        time.Time
+	Duration         time.Duration
+	ProviderDuration time.Duration
+	IssuerDuration   time.Duration
+}
+
+// Filter selects attempts. Empty fields don't filter.
+type Filter struct {
+	Issuer         string
+	HolderDID      string
+	CredentialID   string
+	CredentialType string
+	Outcome        string
+	Since          time.Time
+	Until          time.Time
+	// Limit is the maximal number of attempts, DefaultLimit if zero.
+	Limit  int
+	Offset int
+}
+
+// DefaultLimit is the number of attempts returned if the filter has no limit.
+const DefaultLimit = 100
+
+// Storage keeps refresh attempts and refresh counts.
+type Storage interface {
+	// SaveAttempt stores the attempt and sets its ID.
+	SaveAttempt(ctx context.Context, attempt *Attempt) error
+	// ListAttempts returns attempts that match the filter, the latest first.
+	ListAttempts(ctx context.Context, filter Filter) ([]Attempt, error)
+
+	// CountRefreshes returns the number of refreshes counted by the key.
+	CountRefreshes(ctx context.Context, key string) (int, error)
+	// ReserveRefresh atomically counts a refresh by the key if less than limit refreshes
+	// were counted. A zero limit means no limit. False is returned if the limit is reached.
+	ReserveRefresh(ctx context.Context, key string, limit int) (bool, error)
+	// ReleaseRefresh uncounts a reserved refresh.
+	ReleaseRefresh(ctx context.Context, key string) error
+
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Open opens the storage by URL and creates the schema if it doesn't exist.
+// Supported URLs are 'sqlite://<path>' and 'postgres://...'.
+func Open(ctx context.Context, url string) (Storage, error) {
+	switch {
+	case strings.HasPrefix(url, "sqlite://"):
+		return openSQL(ctx, sqlite, strings.TrimPrefix(url, "sqlite://"))
+	case strings.HasPrefix(url, "postgres://"), strings.HasPrefix(url, "postgresql://"):
+		return openSQL(ctx, postgres, url)
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedStorage, "url '%s'", redact(url))
+	}
+}
+
+// redact hides the password in the URL.
+func redact(url string) string {
+	scheme, rest, ok := strings.Cut(url, "://")
+	if !ok {
+		return url
+	}
+	userinfo, host, ok := strings.Cut(rest, "@")
+	if !ok {
+		return url
+	}
+	user, _, _ := strings.Cut(userinfo, ":")
+	return scheme + "://" + user + ":***@" + host
+}